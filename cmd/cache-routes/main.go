@@ -1,109 +1,80 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 
 	"github.com/hunterjsb/tokygo/internal"
+	"github.com/hunterjsb/tokygo/internal/mapbox"
 )
 
-type MapboxResponse struct {
-	Routes []struct {
-		Geometry struct {
-			Type        string      `json:"type"`
-			Coordinates [][]float64 `json:"coordinates"`
-		} `json:"geometry"`
-		Distance float64 `json:"distance"`
-		Duration float64 `json:"duration"`
-	} `json:"routes"`
-}
-
 func main() {
-	token := os.Getenv("MAPBOX_TOKEN")
-	if token == "" {
-		fmt.Println("Error: MAPBOX_TOKEN not set")
+	providerName := flag.String("provider", "mapbox", "routing provider: mapbox, osrm or greatcircle")
+	osrmURL := flag.String("osrm-url", os.Getenv("OSRM_URL"), "OSRM server URL for -provider osrm")
+	railPath := flag.String("rail", os.Getenv("RAIL_NETWORK"), "route trains along track from an OSM XML extract (.osm) or GTFS shapes.txt, feed directory or .zip")
+	railSpeed := flag.Float64("rail-speed", 0, "average train speed in km/h for -rail (0 uses the Shinkansen default of 230)")
+	flag.Parse()
+
+	var provider internal.RoutingProvider
+	switch *providerName {
+	case "mapbox":
+		token := os.Getenv("MAPBOX_TOKEN")
+		if token == "" {
+			fmt.Println("Error: MAPBOX_TOKEN not set (use -provider osrm or -provider greatcircle to route without Mapbox)")
+			os.Exit(1)
+		}
+		provider = &internal.MapboxRouting{Client: mapbox.New(token)}
+	case "osrm":
+		if *osrmURL == "" {
+			fmt.Println("Error: -osrm-url or OSRM_URL not set")
+			os.Exit(1)
+		}
+		provider = internal.NewOSRMRouting(*osrmURL)
+	case "greatcircle":
+		provider = internal.GreatCircleRouting{}
+	default:
+		fmt.Printf("Error: unknown provider %q (use mapbox, osrm or greatcircle)\n", *providerName)
 		os.Exit(1)
 	}
 
-	fmt.Println("🗺️  Fetching real routes from Mapbox...")
+	if *railPath != "" {
+		network, err := internal.LoadRailNetwork(*railPath)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("🚆 Loaded rail network: %d track points\n", network.Len())
+		provider = &internal.RailRouting{Network: network, Fallback: provider, SpeedKmh: *railSpeed}
+	}
+
+	ctx := context.Background()
+
+	fmt.Printf("🗺️  Fetching routes from %s...\n", provider.Name())
 
 	cachedRoutes := make([]internal.CachedRoute, 0)
 
 	for _, route := range internal.TripRoutes {
 		fmt.Printf("Fetching: %s (%s)\n", route.Name, route.Type)
 
-		// Build waypoints list
-		waypoints := []internal.Location{route.Origin}
-		waypoints = append(waypoints, route.Waypoints...)
-		waypoints = append(waypoints, route.Destination)
-
-		// Build coordinate string
-		coordString := ""
-		for i, wp := range waypoints {
-			if i > 0 {
-				coordString += ";"
-			}
-			coordString += fmt.Sprintf("%f,%f", wp.Lng, wp.Lat)
-		}
-
-		// Determine Mapbox profile
-		profile := "mapbox/driving"
-		switch route.Type {
-		case "train":
-			profile = "mapbox/driving" // Use driving as approximation for rail
-		case "walk":
-			profile = "mapbox/walking"
-		case "car":
-			profile = "mapbox/driving"
-		}
-
-		// Fetch from Mapbox
-		url := fmt.Sprintf("https://api.mapbox.com/directions/v5/%s/%s?access_token=%s&geometries=geojson&overview=full",
-			profile, coordString, token)
-
-		resp, err := http.Get(url)
+		result, err := provider.Route(ctx, route.Type, internal.RouteWaypoints(route))
 		if err != nil {
 			fmt.Printf("  ❌ Error: %v\n", err)
 			continue
 		}
 
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			fmt.Printf("  ❌ Error reading response: %v\n", err)
-			continue
-		}
-
-		var mapboxResp MapboxResponse
-		if err := json.Unmarshal(body, &mapboxResp); err != nil {
-			fmt.Printf("  ❌ Error parsing response: %v\n", err)
-			continue
-		}
-
-		if len(mapboxResp.Routes) == 0 {
-			fmt.Printf("  ❌ No routes found\n")
-			continue
-		}
-
-		mbRoute := mapboxResp.Routes[0]
 		fmt.Printf("  ✅ Got route: %.1f km, %.0f min\n",
-			mbRoute.Distance/1000, mbRoute.Duration/60)
-
-		// Create cached route
-		cached := internal.CachedRoute{
-			Name:        route.Name,
-			Type:        route.Type,
-			Origin:      route.Origin,
-			Destination: route.Destination,
-			Geometry:    mbRoute.Geometry.Coordinates,
-			Distance:    mbRoute.Distance,
-			Duration:    mbRoute.Duration,
-		}
+			result.Distance/1000, result.Duration/60)
 
-		cachedRoutes = append(cachedRoutes, cached)
+		cachedRoutes = append(cachedRoutes, result.CachedRoute(route))
+	}
+
+	// Keep the existing file rather than replacing it with nothing
+	if len(cachedRoutes) == 0 {
+		fmt.Println("\nError: no routes fetched; internal/cached_routes.json left unchanged")
+		os.Exit(1)
 	}
 
 	// Save to JSON file
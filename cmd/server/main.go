@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -42,9 +44,13 @@ func main() {
 		log.Printf("Warning: Could not load .env file: %v", err)
 	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Load configuration (defaults < config file < environment < flags)
+	cfg, err := internal.LoadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
 	}
 
 	// Get the root directory (find it by looking for go.mod)
@@ -62,11 +68,18 @@ func main() {
 		}
 	}
 
+	// Open the trip store (seeded with the built-in trip on first run)
+	store, err := internal.OpenStore(cfg.DatabasePath)
+	if err != nil {
+		log.Fatalf("Error opening trip store: %v", err)
+	}
+	defer store.Close()
+
 	// Create server and register handlers
-	server := internal.NewServer(rootDir)
+	server := internal.NewServer(rootDir, cfg, store)
 	server.RegisterHandlers()
 
-	addr := fmt.Sprintf(":%s", port)
+	addr := fmt.Sprintf(":%s", cfg.Port)
 	frontendDir := filepath.Join(rootDir, "frontend", "dist")
 
 	fmt.Printf("🚀 Server starting on http://localhost%s\n", addr)
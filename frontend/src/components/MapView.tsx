@@ -183,6 +183,24 @@ export function MapView({ routesVisible }: MapViewProps) {
     let lastResUpdateZoom = 0;
     let inflightController: AbortController | null = null;
     let updateTimeout: NodeJS.Timeout | null = null;
+    // Neighbor lists are fetched on first hover rather than with the grid
+    const neighborCache = new Map<string, string[]>();
+
+    async function fetchNeighbors(h3Index: string): Promise<string[]> {
+      const cached = neighborCache.get(h3Index);
+      if (cached) return cached;
+
+      const resp = await fetch(`${API_BASE_URL}/api/h3/neighbors`, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ cells: [h3Index] }),
+      });
+      if (!resp.ok) return [];
+      const data = await resp.json();
+      const neighbors: string[] = data.neighbors[h3Index] || [];
+      neighborCache.set(h3Index, neighbors);
+      return neighbors;
+    }
 
     function computeBaseResolution(zoom: number): number {
       const res = Math.floor(0.9 * zoom - 2.2);
@@ -218,20 +236,45 @@ export function MapView({ routesVisible }: MapViewProps) {
       const targetResolution = updateActiveResolution(zoom);
 
       const bounds = map.current.getBounds();
-      const url = `${API_BASE_URL}/api/h3/grid_window?minLat=${bounds.getSouth()}&minLng=${bounds.getWest()}&maxLat=${bounds.getNorth()}&maxLng=${bounds.getEast()}&resolution=${targetResolution}`;
+      const windowUrl = (resolution: number) =>
+        `${API_BASE_URL}/api/h3/grid_window?minLat=${bounds.getSouth()}&minLng=${bounds.getWest()}&maxLat=${bounds.getNorth()}&maxLng=${bounds.getEast()}&resolution=${resolution}&fields=boundary`;
 
       try {
         if (inflightController) inflightController.abort();
         inflightController = new AbortController();
 
-        const resp = await fetch(url, { signal: inflightController.signal });
-        const data = await resp.json();
+        // Large windows are paginated; follow nextCursor until all cells are loaded
+        const cells: Record<string, any> = {};
+        let resolution = targetResolution;
+        let cursor = "";
+        while (true) {
+          const url = windowUrl(resolution);
+          const pageUrl = cursor ? `${url}&cursor=${cursor}` : url;
+          const resp = await fetch(pageUrl, { signal: inflightController.signal });
+          if (resp.status === 422 && !cursor) {
+            // Too many cells at this resolution: retry at the coarser one the server suggests
+            const problem = await resp.json();
+            const suggested = problem.details?.suggestedResolution;
+            if (typeof suggested === "number" && suggested >= 0 && suggested < resolution) {
+              resolution = suggested;
+              continue;
+            }
+            console.warn("H3 grid unavailable:", problem.detail ?? problem.message);
+            hexGridLayer.current!.clearLayers();
+            return;
+          }
+          if (!resp.ok) return;
+          const page = await resp.json();
+          Object.assign(cells, page.cells);
+          cursor = page.nextCursor ?? "";
+          if (!cursor) break;
+        }
 
         // Clear existing hex grid
         hexGridLayer.current!.clearLayers();
 
         // Add hex cells
-        Object.entries(data.cells).forEach(
+        Object.entries(cells).forEach(
           ([h3Index, cellData]: [string, any]) => {
             const coords = cellData.boundary.map((coord: number[]) => [
               coord[1],
@@ -246,13 +289,22 @@ export function MapView({ routesVisible }: MapViewProps) {
               fillOpacity: 0.01,
             });
 
-            polygon.on("mouseover", () => {
+            let hovered = false;
+
+            polygon.on("mouseover", async () => {
+              hovered = true;
               polygon.setStyle({
                 fillOpacity: 0.35,
               });
 
               // Highlight neighbors
-              const neighbors = cellData.neighbors || [];
+              let neighbors: string[] = [];
+              try {
+                neighbors = await fetchNeighbors(h3Index);
+              } catch (e) {
+                // Hover still works without neighbor highlighting
+              }
+              if (!hovered) return;
               hexGridLayer.current?.eachLayer((layer: any) => {
                 const layerH3 = layer.options.h3Index;
                 if (neighbors.includes(layerH3)) {
@@ -264,6 +316,7 @@ export function MapView({ routesVisible }: MapViewProps) {
             });
 
             polygon.on("mouseout", () => {
+              hovered = false;
               polygon.setStyle({
                 fillOpacity: 0.01,
               });
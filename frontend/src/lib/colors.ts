@@ -10,6 +10,8 @@ export const COLORS = {
     hotel: "#60a5fa",
     airport: "#f87171",
     station: "#a78bfa",
+    restaurant: "#fbbf24",
+    sight: "#34d399",
     default: "#94a3b8",
   },
 } as const;
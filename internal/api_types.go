@@ -12,10 +12,37 @@ type CitiesResponse struct {
 	Colors map[string]string `json:"colors"`
 }
 
-// ConfigResponse is returned by /api/config.
+// ConfigResponse is returned by /api/config: the effective server settings
+// after defaults, config file, environment and flags are applied. File paths
+// (database, cache directory, region file) are not included.
 type ConfigResponse struct {
 	Resolution int     `json:"resolution"`
 	RadiusKm   float64 `json:"radiusKm"`
+
+	GridRegion         BBox    `json:"gridRegion"`         // bounds of the area /api/h3/grid covers
+	GridRegionPolygons int     `json:"gridRegionPolygons"` // polygons in the region (1 for a bbox)
+	GridMaxCells       int     `json:"gridMaxCells"`
+	GridWindowMaxCells int     `json:"gridWindowMaxCells"`
+	GridWindowPageSize int     `json:"gridWindowPageSize"`
+	GridCacheMaxCells  int     `json:"gridCacheMaxCells"`
+	GridCacheTTL       float64 `json:"gridCacheTtlSeconds"`
+
+	MapboxCacheMaxEntries  int     `json:"mapboxCacheMaxEntries"`
+	MapboxCacheTTL         float64 `json:"mapboxCacheTtlSeconds"`
+	MapboxCacheOnDisk      bool    `json:"mapboxCacheOnDisk"`
+	MapboxCacheDirMaxFiles int     `json:"mapboxCacheDirMaxFiles"`
+	MapboxRateLimit        int     `json:"mapboxRateLimit"` // requests per minute per client IP; 0 is unlimited
+	MapboxRateBurst        int     `json:"mapboxRateBurst"`
+}
+
+// TripsResponse is returned by /api/trips.
+type TripsResponse struct {
+	Trips []Trip `json:"trips"`
+}
+
+// ScheduleRequest is the body accepted by PUT /api/itinerary.
+type ScheduleRequest struct {
+	Items []ScheduleItem `json:"items"`
 }
 
 // RoutesResponse is returned by /api/routes.
@@ -28,11 +55,12 @@ type RoutesResponse struct {
 type H3Boundary [][]float64
 
 // H3CellInfo is a canonical representation of an H3 cell with its geometry,
-// center point, and neighbor references. Used in grid endpoints.
+// center point, and neighbor references. Used in grid endpoints, where the
+// fields= parameter can leave any of them out.
 type H3CellInfo struct {
-	Boundary  H3Boundary `json:"boundary"`
-	Center    []float64  `json:"center"`    // [lng, lat]
-	Neighbors []string   `json:"neighbors"` // neighbor H3 indexes
+	Boundary  H3Boundary `json:"boundary,omitempty"`
+	Center    []float64  `json:"center,omitempty"`    // [lng, lat]
+	Neighbors []string   `json:"neighbors,omitempty"` // neighbor H3 indexes
 }
 
 // H3CellResponse is returned by /api/h3/cell.
@@ -41,21 +69,58 @@ type H3CellResponse struct {
 	Boundary H3Boundary `json:"boundary"`
 }
 
+// H3CellParent is one ancestor of a cell in /api/h3/cell/{index}
+type H3CellParent struct {
+	Resolution int    `json:"resolution"`
+	H3Index    string `json:"h3_index"`
+}
+
+// H3CellChildren describes a cell's descendants at one finer resolution.
+// Cells is only listed when there are few enough of them.
+type H3CellChildren struct {
+	Resolution int      `json:"resolution"`
+	Count      int      `json:"count"`
+	Cells      []string `json:"cells,omitempty"`
+}
+
+// H3CellDetailsResponse is returned by /api/h3/cell/{index}: a cell's place in
+// the H3 hierarchy along with its geometry and size.
+type H3CellDetailsResponse struct {
+	H3Index          string          `json:"h3_index"`
+	Resolution       int             `json:"resolution"`
+	BaseCell         int             `json:"base_cell"`
+	IsPentagon       bool            `json:"is_pentagon"`
+	IsResClassIII    bool            `json:"is_res_class_iii"`
+	IcosahedronFaces []int           `json:"icosahedron_faces"`
+	Center           []float64       `json:"center"` // [lng, lat]
+	Boundary         H3Boundary      `json:"boundary"`
+	AreaKm2          float64         `json:"area_km2"`
+	AreaM2           float64         `json:"area_m2"`
+	EdgeLengthKm     float64         `json:"edge_length_km"` // mean of this cell's edges
+	EdgeLengthM      float64         `json:"edge_length_m"`
+	Parents          []H3CellParent  `json:"parents"` // coarsest (resolution 0) first
+	Children         *H3CellChildren `json:"children,omitempty"`
+}
+
 // H3RingCell is a single ring neighbor cell within /api/h3/ring response.
 type H3RingCell struct {
 	H3Index  string     `json:"h3_index"`
 	Boundary H3Boundary `json:"boundary"`
+	Distance *int       `json:"distance,omitempty"` // grid distance from the origin (mode=distances)
 }
 
 // H3RingResponse is returned by /api/h3/ring.
 type H3RingResponse struct {
 	Ring []H3RingCell `json:"ring"`
+	K    int          `json:"k"`
+	Mode string       `json:"mode"`
 }
 
 // H3GridResponse is returned by /api/h3/grid.
 type H3GridResponse struct {
 	Cells      map[string]H3CellInfo `json:"cells"`
 	Resolution int                   `json:"resolution"`
+	Compact    bool                  `json:"compact,omitempty"` // cells are compacted to mixed resolutions up to Resolution
 }
 
 // BBox represents a geographic bounding box.
@@ -66,9 +131,99 @@ type BBox struct {
 	MaxLng float64 `json:"maxLng"`
 }
 
-// H3GridWindowResponse is returned by /api/h3/grid_window.
+// GridLimitDetails explains a grid request rejected for covering too many cells
+type GridLimitDetails struct {
+	EstimatedCells      int `json:"estimatedCells"`
+	MaxCells            int `json:"maxCells"`
+	SuggestedResolution int `json:"suggestedResolution"` // finest resolution within MaxCells; -1 if none
+}
+
+// H3GridWindowResponse is returned by /api/h3/grid_window. Cells holds one page;
+// NextCursor is set when more cells remain.
 type H3GridWindowResponse struct {
 	Cells      map[string]H3CellInfo `json:"cells"`
 	Resolution int                   `json:"resolution"`
 	BBox       BBox                  `json:"bbox"`
+	TotalCells int                   `json:"totalCells"`
+	NextCursor string                `json:"nextCursor,omitempty"`
+	Compact    bool                  `json:"compact,omitempty"` // cells are compacted to mixed resolutions up to Resolution
+}
+
+// H3NeighborsRequest is the POST body of /api/h3/neighbors
+type H3NeighborsRequest struct {
+	Cells []string `json:"cells"`
+}
+
+// H3NeighborsResponse maps each requested H3 index to its neighbor indexes
+type H3NeighborsResponse struct {
+	Neighbors map[string][]string `json:"neighbors"`
+}
+
+// H3UncompactRequest is the POST body of /api/h3/uncompact
+type H3UncompactRequest struct {
+	Cells      []string `json:"cells"`
+	Resolution int      `json:"resolution"`
+}
+
+// H3UncompactResponse is returned by /api/h3/uncompact
+type H3UncompactResponse struct {
+	Cells      []string `json:"cells"` // sorted H3 indexes, all at Resolution
+	Resolution int      `json:"resolution"`
+}
+
+// Error codes used in APIError.Code
+const (
+	ErrCodeInvalidBody       = "invalid_body"
+	ErrCodeInvalidID         = "invalid_id"
+	ErrCodeRequired          = "required"
+	ErrCodeInvalidValue      = "invalid_value"
+	ErrCodeOutOfRange        = "out_of_range"
+	ErrCodeValidationFailed  = "validation_failed"
+	ErrCodeNotFound          = "not_found"
+	ErrCodeConflict          = "conflict"
+	ErrCodeMethodNotAllowed  = "method_not_allowed"
+	ErrCodeUnsupportedFormat = "unsupported_format"
+	ErrCodeNotAcceptable     = "not_acceptable"
+	ErrCodeNotConfigured     = "not_configured"
+	ErrCodeUpstream          = "upstream_error"
+	ErrCodeRateLimited       = "rate_limited"
+	ErrCodeInternal          = "internal_error"
+)
+
+// APIError is the body returned when an API request fails: an RFC 7807 problem
+// details document (application/problem+json). Type, Title, Status and Detail are
+// the standard members and are filled in by writeError; Code, Message, Field and
+// Details are extensions. Code is the machine-readable error, Field names the
+// offending request field, if any, and Details carries extra context such as the
+// allowed values or the individual field errors of a failed validation.
+type APIError struct {
+	Type    string `json:"type,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Status  int    `json:"status,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+	Details any    `json:"details,omitempty"`
+}
+
+// HealthResponse is returned by /health.
+type HealthResponse struct {
+	Status  string `json:"status"`
+	Version string `json:"version"`
+}
+
+// ReadinessChecks reports the state of each dependency checked by /ready.
+type ReadinessChecks struct {
+	Store        bool `json:"store"`        // trip store answers queries
+	CachedRoutes bool `json:"cachedRoutes"` // cached_routes.json loaded with at least one route
+	FrontendDist bool `json:"frontendDist"` // frontend/dist exists under the server root
+	MapboxToken  bool `json:"mapboxToken"`  // MAPBOX_TOKEN is set for the proxy endpoints
+}
+
+// ReadinessResponse is returned by /ready.
+type ReadinessResponse struct {
+	Status  string          `json:"status"` // "ready" or "not_ready"
+	Version string          `json:"version"`
+	Checks  ReadinessChecks `json:"checks"`
 }
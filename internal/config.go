@@ -0,0 +1,491 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds server configuration
+type Config struct {
+	Port         string
+	Resolution   int
+	RadiusKm     float64
+	DatabasePath string // SQLite trip store; empty keeps trip data in memory
+
+	GridRegion   GridRegion // area covered by /api/h3/grid
+	GridMaxCells int        // largest /api/h3/grid response, in cells
+
+	GridWindowMaxCells int // largest /api/h3/grid_window bbox, in estimated cells across all pages
+	GridWindowPageSize int // default and maximum cells per /api/h3/grid_window page
+
+	GridCacheMaxCells int           // total H3 cells held by the grid cache; 0 disables it
+	GridCacheTTL      time.Duration // how long a computed grid stays cached; 0 never expires
+
+	MapboxCacheMaxEntries  int           // Mapbox proxy responses kept in memory; 0 disables caching
+	MapboxCacheTTL         time.Duration // how long a Mapbox response stays cached
+	MapboxCacheDir         string        // on-disk store for Mapbox responses; empty keeps them in memory only
+	MapboxCacheDirMaxFiles int           // most responses kept in MapboxCacheDir
+
+	MapboxRateLimit int // Mapbox proxy requests per minute per client IP; 0 disables limiting
+	MapboxRateBurst int // Mapbox proxy requests a client may make at once
+}
+
+// mapboxMaxCacheTTL is the longest Mapbox responses may be cached. Mapbox terms
+// allow only temporary caching of API results.
+const mapboxMaxCacheTTL = 30 * 24 * time.Hour
+
+// DefaultConfig returns the built-in configuration defaults
+func DefaultConfig() Config {
+	return Config{
+		Port:         "8080",
+		Resolution:   7,
+		RadiusKm:     15.0,
+		DatabasePath: "tokygo.db",
+
+		GridRegion:   DefaultGridRegion,
+		GridMaxCells: 100000,
+
+		GridWindowMaxCells: 200000,
+		GridWindowPageSize: 10000,
+
+		GridCacheMaxCells: 200000,
+		GridCacheTTL:      time.Hour,
+
+		MapboxCacheMaxEntries:  1000,
+		MapboxCacheTTL:         24 * time.Hour,
+		MapboxCacheDirMaxFiles: 10000,
+
+		MapboxRateLimit: 30,
+		MapboxRateBurst: 10,
+	}
+}
+
+// configSetting describes one configuration value and the names it goes by
+// in each layer (config file, environment, command-line flag).
+type configSetting struct {
+	key   string
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var configSettings = []configSetting{
+	{
+		key:   "port",
+		env:   "PORT",
+		flag:  "port",
+		usage: "HTTP port to listen on",
+		set: func(c *Config, value string) error {
+			c.Port = value
+			return nil
+		},
+	},
+	{
+		key:   "h3_resolution",
+		env:   "H3_RESOLUTION",
+		flag:  "resolution",
+		usage: "default H3 resolution (0-15)",
+		set: func(c *Config, value string) error {
+			res, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("must be an integer, got %q", value)
+			}
+			c.Resolution = res
+			return nil
+		},
+	},
+	{
+		key:   "h3_radius_km",
+		env:   "H3_RADIUS_KM",
+		flag:  "radius-km",
+		usage: "H3 display radius in kilometers",
+		set: func(c *Config, value string) error {
+			radius, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("must be a number, got %q", value)
+			}
+			c.RadiusKm = radius
+			return nil
+		},
+	},
+	{
+		key:   "db_path",
+		env:   "DB_PATH",
+		flag:  "db",
+		usage: "SQLite database file for trip data (empty for in-memory)",
+		set: func(c *Config, value string) error {
+			c.DatabasePath = value
+			return nil
+		},
+	},
+	{
+		key:   "grid_region",
+		env:   "GRID_REGION",
+		flag:  "grid-region",
+		usage: "area covered by /api/h3/grid: minLat,minLng,maxLat,maxLng or a GeoJSON polygon file (empty for the Japan bbox)",
+		set: func(c *Config, value string) error {
+			if value == "" {
+				c.GridRegion = DefaultGridRegion
+				return nil
+			}
+			region, err := ParseGridRegion(value)
+			if err != nil {
+				return err
+			}
+			c.GridRegion = region
+			return nil
+		},
+	},
+	{
+		key:   "grid_max_cells",
+		env:   "GRID_MAX_CELLS",
+		flag:  "grid-max-cells",
+		usage: "maximum number of cells /api/h3/grid will return",
+		set: func(c *Config, value string) error {
+			cells, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("must be an integer, got %q", value)
+			}
+			c.GridMaxCells = cells
+			return nil
+		},
+	},
+	{
+		key:   "grid_window_max_cells",
+		env:   "GRID_WINDOW_MAX_CELLS",
+		flag:  "grid-window-max-cells",
+		usage: "maximum estimated cells in an /api/h3/grid_window bbox",
+		set: func(c *Config, value string) error {
+			cells, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("must be an integer, got %q", value)
+			}
+			c.GridWindowMaxCells = cells
+			return nil
+		},
+	},
+	{
+		key:   "grid_window_page_size",
+		env:   "GRID_WINDOW_PAGE_SIZE",
+		flag:  "grid-window-page-size",
+		usage: "default and maximum cells per /api/h3/grid_window page",
+		set: func(c *Config, value string) error {
+			size, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("must be an integer, got %q", value)
+			}
+			c.GridWindowPageSize = size
+			return nil
+		},
+	},
+	{
+		key:   "grid_cache_max_cells",
+		env:   "GRID_CACHE_MAX_CELLS",
+		flag:  "grid-cache-max-cells",
+		usage: "maximum H3 cells kept in the grid cache (0 disables caching)",
+		set: func(c *Config, value string) error {
+			cells, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("must be an integer, got %q", value)
+			}
+			c.GridCacheMaxCells = cells
+			return nil
+		},
+	},
+	{
+		key:   "grid_cache_ttl",
+		env:   "GRID_CACHE_TTL",
+		flag:  "grid-cache-ttl",
+		usage: "how long computed H3 grids stay cached, e.g. 30m (0 never expires)",
+		set: func(c *Config, value string) error {
+			ttl, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("must be a duration like 30m or 1h, got %q", value)
+			}
+			c.GridCacheTTL = ttl
+			return nil
+		},
+	},
+	{
+		key:   "mapbox_cache_max_entries",
+		env:   "MAPBOX_CACHE_MAX_ENTRIES",
+		flag:  "mapbox-cache-max-entries",
+		usage: "maximum Mapbox proxy responses kept in memory (0 disables caching)",
+		set: func(c *Config, value string) error {
+			entries, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("must be an integer, got %q", value)
+			}
+			c.MapboxCacheMaxEntries = entries
+			return nil
+		},
+	},
+	{
+		key:   "mapbox_cache_ttl",
+		env:   "MAPBOX_CACHE_TTL",
+		flag:  "mapbox-cache-ttl",
+		usage: "how long Mapbox proxy responses stay cached, e.g. 12h (at most 720h)",
+		set: func(c *Config, value string) error {
+			ttl, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("must be a duration like 30m or 1h, got %q", value)
+			}
+			c.MapboxCacheTTL = ttl
+			return nil
+		},
+	},
+	{
+		key:   "mapbox_cache_dir",
+		env:   "MAPBOX_CACHE_DIR",
+		flag:  "mapbox-cache-dir",
+		usage: "directory for cached Mapbox proxy responses (empty for in-memory only)",
+		set: func(c *Config, value string) error {
+			c.MapboxCacheDir = value
+			return nil
+		},
+	},
+	{
+		key:   "mapbox_cache_dir_max_files",
+		env:   "MAPBOX_CACHE_DIR_MAX_FILES",
+		flag:  "mapbox-cache-dir-max-files",
+		usage: "maximum Mapbox proxy responses kept in the cache directory",
+		set: func(c *Config, value string) error {
+			files, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("must be an integer, got %q", value)
+			}
+			c.MapboxCacheDirMaxFiles = files
+			return nil
+		},
+	},
+	{
+		key:   "mapbox_rate_limit",
+		env:   "MAPBOX_RATE_LIMIT",
+		flag:  "mapbox-rate-limit",
+		usage: "Mapbox proxy requests allowed per minute per client IP (0 disables limiting)",
+		set: func(c *Config, value string) error {
+			limit, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("must be an integer, got %q", value)
+			}
+			c.MapboxRateLimit = limit
+			return nil
+		},
+	},
+	{
+		key:   "mapbox_rate_burst",
+		env:   "MAPBOX_RATE_BURST",
+		flag:  "mapbox-rate-burst",
+		usage: "Mapbox proxy requests a client may make at once before being limited",
+		set: func(c *Config, value string) error {
+			burst, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("must be an integer, got %q", value)
+			}
+			c.MapboxRateBurst = burst
+			return nil
+		},
+	},
+}
+
+// LoadConfig builds the server configuration from, in increasing order of precedence:
+// built-in defaults, an optional config file (-config flag or CONFIG_FILE),
+// environment variables, and command-line flags. The result is validated before
+// it is returned.
+func LoadConfig(args []string) (Config, error) {
+	cfg := DefaultConfig()
+
+	fs := flag.NewFlagSet("tokygo", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	flagValues := make(map[string]*string, len(configSettings))
+	for _, setting := range configSettings {
+		flagValues[setting.flag] = fs.String(setting.flag, "", setting.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	// Config file
+	if *configPath != "" {
+		values, err := readConfigFile(*configPath)
+		if err != nil {
+			return cfg, err
+		}
+		for _, setting := range configSettings {
+			value, ok := values[setting.key]
+			if !ok {
+				continue
+			}
+			delete(values, setting.key)
+			if err := setting.set(&cfg, value); err != nil {
+				return cfg, fmt.Errorf("config file %s: %s %w", *configPath, setting.key, err)
+			}
+		}
+		for key := range values {
+			return cfg, fmt.Errorf("config file %s: unknown key %q", *configPath, key)
+		}
+	}
+
+	// Environment variables. A variable set to "" still applies, so DB_PATH=
+	// selects the in-memory store; settings that need a value reject it.
+	for _, setting := range configSettings {
+		value, ok := os.LookupEnv(setting.env)
+		if !ok {
+			continue
+		}
+		if err := setting.set(&cfg, value); err != nil {
+			return cfg, fmt.Errorf("environment variable %s %w", setting.env, err)
+		}
+	}
+
+	// Command-line flags (only those explicitly set)
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, setting := range configSettings {
+			if setting.flag != f.Name || flagErr != nil {
+				continue
+			}
+			if err := setting.set(&cfg, *flagValues[f.Name]); err != nil {
+				flagErr = fmt.Errorf("flag -%s %w", f.Name, err)
+			}
+		}
+	})
+	if flagErr != nil {
+		return cfg, flagErr
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate checks that every configuration value is within its allowed range
+func (c Config) Validate() error {
+	var errs []error
+
+	port, err := strconv.Atoi(c.Port)
+	if err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("port must be between 1 and 65535, got %q", c.Port))
+	}
+	if c.Resolution < 0 || c.Resolution > 15 {
+		errs = append(errs, fmt.Errorf("h3_resolution must be between 0 and 15, got %d", c.Resolution))
+	}
+	if c.RadiusKm <= 0 {
+		errs = append(errs, fmt.Errorf("h3_radius_km must be greater than 0, got %g", c.RadiusKm))
+	}
+	if c.GridMaxCells <= 0 {
+		errs = append(errs, fmt.Errorf("grid_max_cells must be greater than 0, got %d", c.GridMaxCells))
+	}
+	if c.GridWindowMaxCells <= 0 {
+		errs = append(errs, fmt.Errorf("grid_window_max_cells must be greater than 0, got %d", c.GridWindowMaxCells))
+	}
+	if c.GridWindowPageSize <= 0 {
+		errs = append(errs, fmt.Errorf("grid_window_page_size must be greater than 0, got %d", c.GridWindowPageSize))
+	}
+	if c.GridCacheMaxCells < 0 {
+		errs = append(errs, fmt.Errorf("grid_cache_max_cells must not be negative, got %d", c.GridCacheMaxCells))
+	}
+	if c.GridCacheTTL < 0 {
+		errs = append(errs, fmt.Errorf("grid_cache_ttl must not be negative, got %s", c.GridCacheTTL))
+	}
+	if c.MapboxCacheMaxEntries < 0 {
+		errs = append(errs, fmt.Errorf("mapbox_cache_max_entries must not be negative, got %d", c.MapboxCacheMaxEntries))
+	}
+	if c.MapboxCacheTTL <= 0 || c.MapboxCacheTTL > mapboxMaxCacheTTL {
+		errs = append(errs, fmt.Errorf("mapbox_cache_ttl must be greater than 0 and at most %s, got %s", mapboxMaxCacheTTL, c.MapboxCacheTTL))
+	}
+	if c.MapboxCacheDir != "" && c.MapboxCacheDirMaxFiles < 1 {
+		errs = append(errs, fmt.Errorf("mapbox_cache_dir_max_files must be at least 1, got %d", c.MapboxCacheDirMaxFiles))
+	}
+	if c.MapboxRateLimit < 0 {
+		errs = append(errs, fmt.Errorf("mapbox_rate_limit must not be negative, got %d", c.MapboxRateLimit))
+	}
+	if c.MapboxRateLimit > 0 && c.MapboxRateBurst < 1 {
+		errs = append(errs, fmt.Errorf("mapbox_rate_burst must be at least 1, got %d", c.MapboxRateBurst))
+	}
+
+	return errors.Join(errs...)
+}
+
+// readConfigFile parses a flat YAML (key: value) or TOML (key = value) file.
+// Nested tables and lists are not supported; every setting is a top-level scalar.
+func readConfigFile(path string) (map[string]string, error) {
+	separator := ""
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		separator = ":"
+	case ".toml":
+		separator = "="
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format (use .yaml, .yml or .toml)", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" || line == "---" {
+			continue
+		}
+
+		parts := strings.SplitN(line, separator, 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("config file %s:%d: expected key%svalue", path, lineNum, separator)
+		}
+		// An empty value (key: or key = "") sets the setting to ""
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		if key == "" {
+			return nil, fmt.Errorf("config file %s:%d: expected key%svalue", path, lineNum, separator)
+		}
+		values[key] = unquote(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	return values, nil
+}
+
+// stripComment removes a trailing # comment that is not inside quotes
+func stripComment(line string) string {
+	var quote rune
+	for i, ch := range line {
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquote strips matching single or double quotes around a value
+func unquote(value string) string {
+	if len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if (first == '"' || first == '\'') && first == last {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfigFile writes content to a config file named name in a temp dir
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigEmptyEnvironment(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "mapbox_cache_dir: mapbox_cache\ngrid_region: 35,139,36,140\n")
+	t.Setenv("DB_PATH", "")
+	t.Setenv("MAPBOX_CACHE_DIR", "")
+	t.Setenv("GRID_REGION", "")
+
+	cfg, err := LoadConfig([]string{"-config", path})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.DatabasePath != "" {
+		t.Errorf("DatabasePath = %q, want empty (in-memory) from DB_PATH=", cfg.DatabasePath)
+	}
+	if cfg.MapboxCacheDir != "" {
+		t.Errorf("MapboxCacheDir = %q, want the file's directory turned off by MAPBOX_CACHE_DIR=", cfg.MapboxCacheDir)
+	}
+	if cfg.GridRegion.BBox != DefaultGridRegion.BBox {
+		t.Errorf("GridRegion = %+v, want the default from GRID_REGION=", cfg.GridRegion.BBox)
+	}
+}
+
+func TestLoadConfigUnsetEnvironment(t *testing.T) {
+	t.Setenv("DB_PATH", "restored after the test")
+	os.Unsetenv("DB_PATH")
+	cfg, err := LoadConfig(nil)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.DatabasePath != DefaultConfig().DatabasePath {
+		t.Errorf("DatabasePath = %q, want the default", cfg.DatabasePath)
+	}
+}
+
+func TestLoadConfigEmptyNumberRejected(t *testing.T) {
+	t.Setenv("GRID_MAX_CELLS", "")
+	if _, err := LoadConfig(nil); err == nil {
+		t.Error("GRID_MAX_CELLS= was accepted")
+	}
+}
+
+func TestLoadConfigFileEmptyValues(t *testing.T) {
+	tests := map[string]string{
+		"config.yaml": "db_path:\nmapbox_cache_dir: \"\"\n",
+		"config.toml": "db_path = \"\"\nmapbox_cache_dir =\n",
+	}
+	for name, content := range tests {
+		cfg, err := LoadConfig([]string{"-config", writeConfigFile(t, name, content)})
+		if err != nil {
+			t.Errorf("%s: LoadConfig: %v", name, err)
+			continue
+		}
+		if cfg.DatabasePath != "" || cfg.MapboxCacheDir != "" {
+			t.Errorf("%s: DatabasePath %q, MapboxCacheDir %q; want both empty", name, cfg.DatabasePath, cfg.MapboxCacheDir)
+		}
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "config.toml", "port = \"9000\"\ngrid_max_cells = 5000\n")
+	t.Setenv("PORT", "9001")
+
+	cfg, err := LoadConfig([]string{"-config", path, "-grid-max-cells", "7000"})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Port != "9001" {
+		t.Errorf("Port = %q, want the environment's 9001 over the file", cfg.Port)
+	}
+	if cfg.GridMaxCells != 7000 {
+		t.Errorf("GridMaxCells = %d, want the flag's 7000 over the file", cfg.GridMaxCells)
+	}
+}
@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// handleExportICS returns the trip itinerary as an iCalendar feed
+// that phone calendars can subscribe to
+func (s *Server) handleExportICS(w http.ResponseWriter, r *http.Request) {
+	trip, ok := s.tripFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	items, err := s.Store.Schedule(r.Context(), trip.ID)
+	if err != nil {
+		writeStoreError(w, err, "schedule", trip.ID)
+		return
+	}
+	locations, routes, ok := s.tripPlaces(w, r, trip)
+	if !ok {
+		return
+	}
+
+	itinerary, err := BuildItinerary(trip, items, locations, routes)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, APIError{
+			Code:    ErrCodeInternal,
+			Message: fmt.Sprintf("Error building itinerary: %v", err),
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := WriteICS(&buf, trip, itinerary, time.Now()); err != nil {
+		writeError(w, http.StatusInternalServerError, APIError{
+			Code:    ErrCodeInternal,
+			Message: fmt.Sprintf("Error generating calendar: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s.ics"`, exportFilename(trip)))
+	w.Write(buf.Bytes())
+}
+
+// handleExportGPX returns the trip's locations as waypoints and routes as tracks in GPX
+func (s *Server) handleExportGPX(w http.ResponseWriter, r *http.Request) {
+	trip, ok := s.tripFromRequest(w, r)
+	if !ok {
+		return
+	}
+	locations, routes, ok := s.tripPlaces(w, r, trip)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := WriteGPX(&buf, trip, locations, CachedRoutesFor(routes)); err != nil {
+		writeError(w, http.StatusInternalServerError, APIError{
+			Code:    ErrCodeInternal,
+			Message: fmt.Sprintf("Error generating GPX: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gpx+xml")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.gpx"`, exportFilename(trip)))
+	w.Write(buf.Bytes())
+}
+
+// handleExportKML returns the trip's locations and routes as styled KML for Google Earth
+func (s *Server) handleExportKML(w http.ResponseWriter, r *http.Request) {
+	trip, ok := s.tripFromRequest(w, r)
+	if !ok {
+		return
+	}
+	cities, err := s.Store.Cities(r.Context(), trip.ID)
+	if err != nil {
+		writeStoreError(w, err, "cities", trip.ID)
+		return
+	}
+	locations, routes, ok := s.tripPlaces(w, r, trip)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := WriteKML(&buf, trip, cities, locations, CachedRoutesFor(routes)); err != nil {
+		writeError(w, http.StatusInternalServerError, APIError{
+			Code:    ErrCodeInternal,
+			Message: fmt.Sprintf("Error generating KML: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.google-earth.kml+xml")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.kml"`, exportFilename(trip)))
+	w.Write(buf.Bytes())
+}
+
+var nonFilenameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// exportFilename returns a filesystem-safe base name for a trip's exports
+func exportFilename(trip Trip) string {
+	name := strings.Trim(nonFilenameChars.ReplaceAllString(strings.ToLower(trip.Name), "-"), "-")
+	if name == "" {
+		return fmt.Sprintf("trip-%d", trip.ID)
+	}
+	return name
+}
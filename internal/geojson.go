@@ -1,5 +1,13 @@
 package internal
 
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/uber/h3-go/v4"
+)
+
 // GeoJSON represents a GeoJSON FeatureCollection
 type GeoJSON struct {
 	Type     string    `json:"type"`
@@ -18,3 +26,80 @@ type Geometry struct {
 	Type        string      `json:"type"`
 	Coordinates interface{} `json:"coordinates"` // Can be [][]float64 (LineString) or [][][]float64 (Polygon)
 }
+
+// WKT returns the geometry as Well-Known Text, e.g. "POINT (139.7 35.6)".
+// Unsupported geometry types return an empty string.
+func (g Geometry) WKT() string {
+	switch coords := g.Coordinates.(type) {
+	case []float64:
+		return "POINT (" + wktPosition(coords) + ")"
+	case [][]float64:
+		if g.Type == "Polygon" {
+			return "POLYGON (" + wktRing(coords) + ")"
+		}
+		return "LINESTRING " + wktRing(coords)
+	case [][][]float64:
+		rings := make([]string, len(coords))
+		for i, ring := range coords {
+			rings[i] = wktRing(ring)
+		}
+		return "POLYGON (" + strings.Join(rings, ", ") + ")"
+	}
+	return ""
+}
+
+func wktPosition(p []float64) string {
+	return strconv.FormatFloat(p[0], 'f', -1, 64) + " " + strconv.FormatFloat(p[1], 'f', -1, 64)
+}
+
+func wktRing(ring [][]float64) string {
+	points := make([]string, len(ring))
+	for i, p := range ring {
+		points[i] = wktPosition(p)
+	}
+	return "(" + strings.Join(points, ", ") + ")"
+}
+
+// H3CellsGeoJSON converts grid cells to features ordered by H3 index, with the
+// index, resolution, center and neighbors as properties. Cells projected without
+// a boundary become Point features at their center, and absent fields are left
+// out of the properties. Resolution is read from each index, so compacted
+// (mixed-resolution) grids work too.
+func H3CellsGeoJSON(cells map[string]H3CellInfo) *GeoJSON {
+	indexes := make([]string, 0, len(cells))
+	for index := range cells {
+		indexes = append(indexes, index)
+	}
+	slices.Sort(indexes)
+
+	features := make([]Feature, 0, len(cells))
+	for _, index := range indexes {
+		cell := cells[index]
+		geometry := Geometry{Type: "Point", Coordinates: cell.Center}
+		if cell.Boundary != nil {
+			geometry = Geometry{Type: "Polygon", Coordinates: [][][]float64{cell.Boundary}}
+		}
+
+		properties := map[string]any{
+			"h3_index":   index,
+			"resolution": h3.Cell(h3.IndexFromString(index)).Resolution(),
+		}
+		if cell.Center != nil {
+			properties["center"] = cell.Center
+		}
+		if cell.Neighbors != nil {
+			properties["neighbors"] = cell.Neighbors
+		}
+
+		features = append(features, Feature{
+			Type:       "Feature",
+			Geometry:   geometry,
+			Properties: properties,
+		})
+	}
+
+	return &GeoJSON{
+		Type:     "FeatureCollection",
+		Features: features,
+	}
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// gpxSymbols maps location types to the Garmin waypoint symbol names most GPX apps recognize
+var gpxSymbols = map[LocationType]string{
+	LocationTypeHotel:      "Lodging",
+	LocationTypeAirport:    "Airport",
+	LocationTypeStation:    "Ground Transportation",
+	LocationTypeRestaurant: "Restaurant",
+	LocationTypeSight:      "Scenic Area",
+}
+
+// GPX 1.1 document (https://www.topografix.com/GPX/1/1/)
+type gpxDocument struct {
+	XMLName   xml.Name      `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Metadata  gpxMetadata   `xml:"metadata"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+	Tracks    []gpxTrack    `xml:"trk"`
+}
+
+type gpxMetadata struct {
+	Name string `xml:"name"`
+}
+
+type gpxWaypoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Name string  `xml:"name"`
+	Desc string  `xml:"desc,omitempty"`
+	Sym  string  `xml:"sym,omitempty"`
+	Type string  `xml:"type,omitempty"`
+}
+
+type gpxTrack struct {
+	Name     string       `xml:"name"`
+	Desc     string       `xml:"desc,omitempty"`
+	Type     string       `xml:"type,omitempty"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
+
+// WriteGPX writes the trip as GPX 1.1: locations become <wpt> waypoints and
+// each cached route geometry becomes a single-segment <trk> track.
+func WriteGPX(w io.Writer, trip Trip, locations []TripLocation, routes []CachedRoute) error {
+	doc := gpxDocument{
+		Version:  "1.1",
+		Creator:  "tokygo",
+		Metadata: gpxMetadata{Name: trip.Name},
+	}
+
+	for _, loc := range locations {
+		doc.Waypoints = append(doc.Waypoints, gpxWaypoint{
+			Lat:  loc.Lat,
+			Lon:  loc.Lng,
+			Name: loc.Name,
+			Desc: loc.City,
+			Sym:  gpxSymbols[loc.Type],
+			Type: string(loc.Type),
+		})
+	}
+
+	for _, route := range routes {
+		segment := gpxSegment{Points: make([]gpxPoint, 0, len(route.Geometry))}
+		for _, coord := range route.Geometry {
+			segment.Points = append(segment.Points, gpxPoint{Lat: coord[1], Lon: coord[0]})
+		}
+		doc.Tracks = append(doc.Tracks, gpxTrack{
+			Name:     route.Name,
+			Desc:     route.Origin.Name + " to " + route.Destination.Name,
+			Type:     route.Type,
+			Segments: []gpxSegment{segment},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
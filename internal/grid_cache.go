@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// gridKey identifies a computed H3 grid by resolution and covered bbox.
+// Region is set for grids of the configured GridRegion, whose polygons may
+// cover less than their bbox. Compact grids are cached separately from the
+// full grids they were compacted from.
+type gridKey struct {
+	Resolution int
+	BBox       BBox
+	Region     string
+	Compact    bool
+}
+
+// gridEntry is one cached grid in the LRU list
+type gridEntry struct {
+	key     gridKey
+	cells   map[string]H3CellInfo
+	expires time.Time
+}
+
+// gridCall is an in-flight grid computation that concurrent misses wait on
+type gridCall struct {
+	done  chan struct{}
+	cells map[string]H3CellInfo
+	err   error
+}
+
+// errGridComputePanicked is returned to callers waiting on a computation that panicked
+var errGridComputePanicked = errors.New("grid computation panicked")
+
+// GridCache is an LRU cache of computed H3 grids. Its size is bounded by the
+// total number of cells held rather than the number of grids, since a single
+// country-wide grid can outweigh hundreds of viewport windows. Concurrent
+// misses for the same key share one computation.
+type GridCache struct {
+	mu       sync.Mutex
+	maxCells int
+	ttl      time.Duration
+	entries  map[gridKey]*list.Element
+	order    *list.List // front is most recently used
+	cells    int
+	inflight map[gridKey]*gridCall
+
+	hits      uint64
+	misses    uint64
+	shared    uint64
+	evictions uint64
+}
+
+// GridCacheStats is a snapshot of cache occupancy and hit/miss counters
+type GridCacheStats struct {
+	Entries    int     `json:"entries"`
+	Cells      int     `json:"cells"`
+	MaxCells   int     `json:"maxCells"`
+	TTLSeconds float64 `json:"ttlSeconds"`
+	Hits       uint64  `json:"hits"`
+	Misses     uint64  `json:"misses"`
+	Shared     uint64  `json:"shared"` // misses served by another request's in-flight computation
+	Evictions  uint64  `json:"evictions"`
+	HitRate    float64 `json:"hitRate"`
+}
+
+// NewGridCache creates a cache holding at most maxCells cells, each grid
+// expiring ttl after it was computed. A maxCells of 0 disables storage (concurrent
+// misses are still coalesced); a ttl of 0 means grids never expire.
+func NewGridCache(maxCells int, ttl time.Duration) *GridCache {
+	return &GridCache{
+		maxCells: maxCells,
+		ttl:      ttl,
+		entries:  make(map[gridKey]*list.Element),
+		order:    list.New(),
+		inflight: make(map[gridKey]*gridCall),
+	}
+}
+
+// Get returns the cached grid for key, calling compute on a miss. The returned
+// map is shared with other callers and must not be modified. hit reports
+// whether the grid came from the cache.
+func (c *GridCache) Get(key gridKey, compute func() (map[string]H3CellInfo, error)) (cells map[string]H3CellInfo, hit bool, err error) {
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*gridEntry)
+		if c.ttl == 0 || time.Now().Before(entry.expires) {
+			c.order.MoveToFront(elem)
+			c.hits++
+			c.mu.Unlock()
+			return entry.cells, true, nil
+		}
+		c.remove(elem)
+	}
+	c.misses++
+
+	if call, ok := c.inflight[key]; ok {
+		c.shared++
+		c.mu.Unlock()
+		<-call.done
+		return call.cells, false, call.err
+	}
+
+	// If compute panics, the error stays set for any waiting callers and the
+	// deferred cleanup still releases them before the panic propagates
+	call := &gridCall{done: make(chan struct{}), err: errGridComputePanicked}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.inflight, key)
+		if call.err == nil {
+			c.add(key, call.cells)
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.cells, call.err = compute()
+	return call.cells, false, call.err
+}
+
+// Stats returns a snapshot of the cache counters
+func (c *GridCache) Stats() GridCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := GridCacheStats{
+		Entries:    c.order.Len(),
+		Cells:      c.cells,
+		MaxCells:   c.maxCells,
+		TTLSeconds: c.ttl.Seconds(),
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Shared:     c.shared,
+		Evictions:  c.evictions,
+	}
+	if total := c.hits + c.misses; total > 0 {
+		stats.HitRate = float64(c.hits) / float64(total)
+	}
+	return stats
+}
+
+// add stores a grid and evicts least recently used grids until the cache fits.
+// Grids larger than the whole cache are not stored. Caller must hold c.mu.
+func (c *GridCache) add(key gridKey, cells map[string]H3CellInfo) {
+	if len(cells) > c.maxCells {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+
+	entry := &gridEntry{key: key, cells: cells, expires: time.Now().Add(c.ttl)}
+	c.entries[key] = c.order.PushFront(entry)
+	c.cells += len(cells)
+
+	for c.cells > c.maxCells {
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+}
+
+// remove drops an entry from the cache. Caller must hold c.mu.
+func (c *GridCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*gridEntry)
+	delete(c.entries, entry.key)
+	c.cells -= len(entry.cells)
+}
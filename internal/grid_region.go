@@ -0,0 +1,209 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/uber/h3-go/v4"
+)
+
+// GridRegion is the area /api/h3/grid covers: either a bounding box or the
+// polygons of a GeoJSON file (for example a land outline of Japan, so the grid
+// does not include open sea).
+type GridRegion struct {
+	Source   string // bbox spec or GeoJSON file path the region was loaded from
+	BBox     BBox   // bounds of all polygons
+	Polygons []h3.GeoPolygon
+}
+
+// DefaultGridRegion is the Japan bounding box the grid has always covered
+var DefaultGridRegion = BBoxRegion(BBox{MinLat: 30.0, MinLng: 128.0, MaxLat: 46.0, MaxLng: 146.0})
+
+// BBoxRegion returns a region covering a bounding box
+func BBoxRegion(bbox BBox) GridRegion {
+	return GridRegion{
+		Source:   fmt.Sprintf("%g,%g,%g,%g", bbox.MinLat, bbox.MinLng, bbox.MaxLat, bbox.MaxLng),
+		BBox:     bbox,
+		Polygons: []h3.GeoPolygon{bboxPolygon(bbox)},
+	}
+}
+
+// bboxPolygon converts a bounding box to an H3 polygon
+func bboxPolygon(bbox BBox) h3.GeoPolygon {
+	return h3.GeoPolygon{GeoLoop: h3.GeoLoop{
+		{Lat: bbox.MinLat, Lng: bbox.MinLng},
+		{Lat: bbox.MinLat, Lng: bbox.MaxLng},
+		{Lat: bbox.MaxLat, Lng: bbox.MaxLng},
+		{Lat: bbox.MaxLat, Lng: bbox.MinLng},
+	}}
+}
+
+// ParseGridRegion reads a region from either a "minLat,minLng,maxLat,maxLng"
+// bounding box or the path of a .geojson/.json file containing Polygon or
+// MultiPolygon geometries (bare, as Features, or in a FeatureCollection).
+func ParseGridRegion(spec string) (GridRegion, error) {
+	switch strings.ToLower(filepath.Ext(spec)) {
+	case ".geojson", ".json":
+		return loadGeoJSONRegion(spec)
+	}
+
+	parts := strings.Split(spec, ",")
+	if len(parts) != 4 {
+		return GridRegion{}, fmt.Errorf("must be minLat,minLng,maxLat,maxLng or a .geojson file, got %q", spec)
+	}
+	var values [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return GridRegion{}, fmt.Errorf("must be minLat,minLng,maxLat,maxLng or a .geojson file, got %q", spec)
+		}
+		values[i] = v
+	}
+
+	bbox := BBox{MinLat: values[0], MinLng: values[1], MaxLat: values[2], MaxLng: values[3]}
+	if bbox.MinLat < -90 || bbox.MaxLat > 90 || bbox.MinLng < -180 || bbox.MaxLng > 180 {
+		return GridRegion{}, fmt.Errorf("bbox %q is outside -90..90 latitude, -180..180 longitude", spec)
+	}
+	if bbox.MinLat >= bbox.MaxLat || bbox.MinLng >= bbox.MaxLng {
+		return GridRegion{}, fmt.Errorf("bbox %q must have min values below max values", spec)
+	}
+	return BBoxRegion(bbox), nil
+}
+
+// regionGeoJSON is the subset of GeoJSON needed to read region polygons
+type regionGeoJSON struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *regionGeoJSON  `json:"geometry"`
+	Features    []regionGeoJSON `json:"features"`
+}
+
+// loadGeoJSONRegion reads every Polygon and MultiPolygon in a GeoJSON file
+func loadGeoJSONRegion(path string) (GridRegion, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return GridRegion{}, err
+	}
+
+	var doc regionGeoJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return GridRegion{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var polygons [][][][]float64
+	var collect func(obj regionGeoJSON) error
+	collect = func(obj regionGeoJSON) error {
+		switch obj.Type {
+		case "FeatureCollection":
+			for _, feature := range obj.Features {
+				if err := collect(feature); err != nil {
+					return err
+				}
+			}
+		case "Feature":
+			if obj.Geometry != nil {
+				return collect(*obj.Geometry)
+			}
+		case "Polygon":
+			var polygon [][][]float64
+			if err := json.Unmarshal(obj.Coordinates, &polygon); err != nil {
+				return err
+			}
+			polygons = append(polygons, polygon)
+		case "MultiPolygon":
+			var multi [][][][]float64
+			if err := json.Unmarshal(obj.Coordinates, &multi); err != nil {
+				return err
+			}
+			polygons = append(polygons, multi...)
+		default:
+			return fmt.Errorf("unsupported geometry type %q (want Polygon or MultiPolygon)", obj.Type)
+		}
+		return nil
+	}
+	if err := collect(doc); err != nil {
+		return GridRegion{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(polygons) == 0 {
+		return GridRegion{}, fmt.Errorf("%s: no polygons found", path)
+	}
+
+	region := GridRegion{
+		Source: path,
+		BBox:   BBox{MinLat: 90, MinLng: 180, MaxLat: -90, MaxLng: -180},
+	}
+	for _, polygon := range polygons {
+		var geo h3.GeoPolygon
+		for i, ring := range polygon {
+			loop := make(h3.GeoLoop, 0, len(ring))
+			for _, position := range ring {
+				if len(position) < 2 {
+					return GridRegion{}, fmt.Errorf("%s: position must have longitude and latitude", path)
+				}
+				ll := h3.LatLng{Lat: position[1], Lng: position[0]}
+				loop = append(loop, ll)
+
+				region.BBox.MinLat = math.Min(region.BBox.MinLat, ll.Lat)
+				region.BBox.MinLng = math.Min(region.BBox.MinLng, ll.Lng)
+				region.BBox.MaxLat = math.Max(region.BBox.MaxLat, ll.Lat)
+				region.BBox.MaxLng = math.Max(region.BBox.MaxLng, ll.Lng)
+			}
+			// GeoJSON rings repeat their first position; H3 loops are implicitly closed
+			if len(loop) > 1 && loop[0] == loop[len(loop)-1] {
+				loop = loop[:len(loop)-1]
+			}
+			if len(loop) < 3 {
+				return GridRegion{}, fmt.Errorf("%s: polygon ring needs at least 3 positions", path)
+			}
+
+			if i == 0 {
+				geo.GeoLoop = loop
+			} else {
+				geo.Holes = append(geo.Holes, loop)
+			}
+		}
+		region.Polygons = append(region.Polygons, geo)
+	}
+
+	return region, nil
+}
+
+// AreaKm2 returns the region's area on the sphere, with holes subtracted
+func (g GridRegion) AreaKm2() float64 {
+	total := 0.0
+	for _, polygon := range g.Polygons {
+		total += loopAreaKm2(polygon.GeoLoop)
+		for _, hole := range polygon.Holes {
+			total -= loopAreaKm2(hole)
+		}
+	}
+	return total
+}
+
+// EstimateCells approximates how many cells at resolution cover the region, from
+// its area and the average hexagon area. It is cheap enough to run before
+// deciding whether to polyfill.
+func (g GridRegion) EstimateCells(resolution int) int {
+	cellAreaKm2, err := h3.HexagonAreaAvgKm2(resolution)
+	if err != nil || cellAreaKm2 <= 0 {
+		return 0
+	}
+	return int(math.Ceil(g.AreaKm2() / cellAreaKm2))
+}
+
+// loopAreaKm2 is the spherical area enclosed by a loop, from the shoelace formula
+// in a cylindrical equal-area projection (exact for bounding boxes)
+func loopAreaKm2(loop h3.GeoLoop) float64 {
+	sum := 0.0
+	for i := range loop {
+		a, b := loop[i], loop[(i+1)%len(loop)]
+		Δλ := (b.Lng - a.Lng) * math.Pi / 180
+		sum += Δλ * (math.Sin(a.Lat*math.Pi/180) + math.Sin(b.Lat*math.Pi/180)) / 2
+	}
+	return math.Abs(sum) * earthRadiusKm * earthRadiusKm
+}
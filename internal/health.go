@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+)
+
+// Version is the build version reported by the health endpoints.
+// Override at build time with:
+//
+//	go build -ldflags "-X github.com/hunterjsb/tokygo/internal.Version=v1.2.3"
+var Version = "dev"
+
+// buildVersion returns Version, falling back to the VCS revision stamped
+// into the binary when no explicit version was provided.
+func buildVersion() string {
+	if Version != "dev" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return Version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 7 {
+			return setting.Value[:7]
+		}
+	}
+	return Version
+}
+
+// handleHealth is the liveness probe. It only confirms the process is serving HTTP.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	response := HealthResponse{
+		Status:  "ok",
+		Version: buildVersion(),
+	}
+
+	okJSON(w, response)
+}
+
+// handleReady is the readiness probe. The server is ready once the trip store
+// answers queries and the cached routes are loaded; the frontend build and Mapbox token are reported but not required,
+// since the frontend is usually served from GitHub Pages.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	_, storeErr := s.Store.Trips(r.Context())
+
+	checks := ReadinessChecks{
+		Store:        storeErr == nil,
+		CachedRoutes: len(CachedRoutes) > 0,
+		FrontendDist: dirExists(filepath.Join(s.RootDir, "frontend", "dist")),
+		MapboxToken:  s.mapbox.Token != "",
+	}
+
+	response := ReadinessResponse{
+		Status:  "ready",
+		Version: buildVersion(),
+		Checks:  checks,
+	}
+
+	status := http.StatusOK
+	if !checks.Store || !checks.CachedRoutes {
+		response.Status = "not_ready"
+		status = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, status, response)
+}
+
+// dirExists reports whether path exists and is a directory
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
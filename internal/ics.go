@@ -0,0 +1,220 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// icsMaxLineOctets is the RFC 5545 content line limit before folding
+const icsMaxLineOctets = 75
+
+// icsLocalLayout and icsUTCLayout are RFC 5545 DATE-TIME forms
+const (
+	icsLocalLayout = "20060102T150405"
+	icsUTCLayout   = "20060102T150405Z"
+)
+
+// WriteICS writes an RFC 5545 calendar for the itinerary: one VEVENT per hotel
+// stay (check-in to check-out) and one per route leg, with times in the trip's
+// timezone described by a VTIMEZONE component. now is used for DTSTAMP.
+func WriteICS(w io.Writer, trip Trip, itinerary *Itinerary, now time.Time) error {
+	tz, err := trip.Location()
+	if err != nil {
+		return fmt.Errorf("trip %d timezone: %w", trip.ID, err)
+	}
+
+	cal := &icsWriter{w: bufio.NewWriter(w)}
+	cal.line("BEGIN:VCALENDAR")
+	cal.line("VERSION:2.0")
+	cal.line("PRODID:-//tokygo//Trip Itinerary//EN")
+	cal.line("CALSCALE:GREGORIAN")
+	cal.line("METHOD:PUBLISH")
+	cal.prop("X-WR-CALNAME", icsText(trip.Name))
+	cal.prop("X-WR-TIMEZONE", trip.Timezone)
+
+	if err := writeVTimezone(cal, trip, tz); err != nil {
+		return err
+	}
+
+	stamp := now.UTC().Format(icsUTCLayout)
+	for _, day := range itinerary.Days {
+		// Items spanning several days are listed on each; emit them once, on the day they start
+		for _, stop := range day.Stops {
+			if stop.Location.Type != LocationTypeHotel || stop.Start.Format(tripDateLayout) != day.Date {
+				continue
+			}
+			loc := stop.Location
+			cal.line("BEGIN:VEVENT")
+			cal.prop("UID", fmt.Sprintf("trip-%d-stay-%d-%s@tokygo", trip.ID, loc.ID, stop.Start.UTC().Format(icsUTCLayout)))
+			cal.prop("DTSTAMP", stamp)
+			cal.prop("DTSTART;TZID="+trip.Timezone, stop.Start.In(tz).Format(icsLocalLayout))
+			cal.prop("DTEND;TZID="+trip.Timezone, stop.End.In(tz).Format(icsLocalLayout))
+			cal.prop("SUMMARY", icsText("Stay: "+loc.Name))
+			cal.prop("DESCRIPTION", icsText(fmt.Sprintf("Check-in %s, check-out %s (%s)",
+				stop.Start.In(tz).Format("Jan 2 15:04"), stop.End.In(tz).Format("Jan 2 15:04"), loc.City)))
+			cal.prop("LOCATION", icsText(loc.Name))
+			cal.prop("GEO", icsGeo(loc.Lat, loc.Lng))
+			cal.prop("CATEGORIES", "LODGING")
+			cal.prop("TRANSP", "TRANSPARENT")
+			cal.line("END:VEVENT")
+		}
+
+		for _, leg := range day.Legs {
+			if leg.Start.Format(tripDateLayout) != day.Date {
+				continue
+			}
+			route := leg.Route
+			cal.line("BEGIN:VEVENT")
+			cal.prop("UID", fmt.Sprintf("trip-%d-leg-%d-%s@tokygo", trip.ID, route.ID, leg.Start.UTC().Format(icsUTCLayout)))
+			cal.prop("DTSTAMP", stamp)
+			cal.prop("DTSTART;TZID="+trip.Timezone, leg.Start.In(tz).Format(icsLocalLayout))
+			cal.prop("DTEND;TZID="+trip.Timezone, leg.End.In(tz).Format(icsLocalLayout))
+			cal.prop("SUMMARY", icsText(route.Name))
+			cal.prop("DESCRIPTION", icsText(fmt.Sprintf("From %s (%.4f, %.4f) to %s (%.4f, %.4f), %.0f km by %s",
+				route.Origin.Name, route.Origin.Lat, route.Origin.Lng,
+				route.Destination.Name, route.Destination.Lat, route.Destination.Lng, route.Distance, route.Type)))
+			cal.prop("LOCATION", icsText(route.Origin.Name+" to "+route.Destination.Name))
+			cal.prop("GEO", icsGeo(route.Origin.Lat, route.Origin.Lng))
+			cal.prop("CATEGORIES", "TRAVEL,"+strings.ToUpper(route.Type))
+			cal.line("END:VEVENT")
+		}
+	}
+
+	cal.line("END:VCALENDAR")
+	return cal.flush()
+}
+
+// writeVTimezone describes tz over the years the trip spans, with one observance
+// for the starting offset and one for each transition (e.g. daylight saving).
+func writeVTimezone(cal *icsWriter, trip Trip, tz *time.Location) error {
+	start, err := time.ParseInLocation(tripDateLayout, trip.StartDate, tz)
+	if err != nil {
+		return fmt.Errorf("trip %d start date: %w", trip.ID, err)
+	}
+	end, err := time.ParseInLocation(tripDateLayout, trip.EndDate, tz)
+	if err != nil {
+		return fmt.Errorf("trip %d end date: %w", trip.ID, err)
+	}
+
+	from := time.Date(start.Year(), time.January, 1, 0, 0, 0, 0, tz)
+	until := time.Date(end.Year()+1, time.January, 1, 0, 0, 0, 0, tz)
+
+	cal.line("BEGIN:VTIMEZONE")
+	cal.prop("TZID", trip.Timezone)
+
+	_, offset := from.Zone()
+	writeObservance(cal, from, offset, offset)
+
+	for day := from; day.Before(until); day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		_, nextOffset := next.Zone()
+		if nextOffset == offset {
+			continue
+		}
+		transition := findTransition(day, next, offset)
+		writeObservance(cal, transition, offset, nextOffset)
+		offset = nextOffset
+	}
+
+	cal.line("END:VTIMEZONE")
+	return nil
+}
+
+// findTransition binary-searches (lo, hi] for the first instant whose UTC offset
+// differs from offset, to the second
+func findTransition(lo, hi time.Time, offset int) time.Time {
+	for hi.Sub(lo) > time.Second {
+		mid := lo.Add(hi.Sub(lo) / 2)
+		if _, o := mid.Zone(); o == offset {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hi
+}
+
+// writeObservance writes a STANDARD or DAYLIGHT component starting at onset.
+// DTSTART is the onset's wall-clock time in the offset being left (RFC 5545 §3.6.5).
+func writeObservance(cal *icsWriter, onset time.Time, fromOffset, toOffset int) {
+	kind := "STANDARD"
+	if onset.IsDST() {
+		kind = "DAYLIGHT"
+	}
+	name, _ := onset.Zone()
+
+	cal.line("BEGIN:" + kind)
+	cal.prop("DTSTART", onset.In(time.FixedZone("", fromOffset)).Format(icsLocalLayout))
+	cal.prop("TZOFFSETFROM", icsOffset(fromOffset))
+	cal.prop("TZOFFSETTO", icsOffset(toOffset))
+	cal.prop("TZNAME", icsText(name))
+	cal.line("END:" + kind)
+}
+
+// icsOffset formats a UTC offset in seconds as +HHMM
+func icsOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign = '-'
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
+
+// icsGeo formats a GEO property value (latitude;longitude)
+func icsGeo(lat, lng float64) string {
+	return fmt.Sprintf("%.6f;%.6f", lat, lng)
+}
+
+// icsText escapes a TEXT property value
+func icsText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// icsWriter writes CRLF-terminated content lines, folding them at 75 octets
+type icsWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (c *icsWriter) prop(name, value string) {
+	c.line(name + ":" + value)
+}
+
+func (c *icsWriter) line(s string) {
+	if c.err != nil {
+		return
+	}
+
+	limit := icsMaxLineOctets
+	for len(s) > limit {
+		// Fold on a rune boundary so multi-byte characters are never split
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if _, c.err = c.w.WriteString(s[:cut] + "\r\n "); c.err != nil {
+			return
+		}
+		s = s[cut:]
+		limit = icsMaxLineOctets - 1 // continuation lines start with a space
+	}
+	_, c.err = c.w.WriteString(s + "\r\n")
+}
+
+func (c *icsWriter) flush() error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.w.Flush()
+}
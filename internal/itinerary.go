@@ -0,0 +1,308 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ScheduleKind distinguishes the entries of a trip schedule
+type ScheduleKind string
+
+const (
+	ScheduleKindStop ScheduleKind = "stop" // time spent at a TripLocation
+	ScheduleKindLeg  ScheduleKind = "leg"  // travel along a Route
+)
+
+// connectionToleranceKm is how far a leg's endpoint may be from the adjacent
+// stop (or leg) and still count as connected
+const connectionToleranceKm = 1.0
+
+// ScheduleItem is one stored entry of a trip's itinerary: a stop references a
+// TripLocation by LocationID, a leg references a Route by RouteID.
+type ScheduleItem struct {
+	Kind       ScheduleKind `json:"kind"`
+	LocationID int64        `json:"locationId,omitempty"`
+	RouteID    int64        `json:"routeId,omitempty"`
+	Start      time.Time    `json:"start"`
+	End        time.Time    `json:"end"`
+}
+
+// ItineraryStop is a scheduled visit to a trip location
+type ItineraryStop struct {
+	LocationID int64        `json:"locationId"`
+	Location   TripLocation `json:"location"`
+	Start      time.Time    `json:"start"`
+	End        time.Time    `json:"end"`
+}
+
+// ItineraryLeg is a scheduled trip along a route
+type ItineraryLeg struct {
+	RouteID int64     `json:"routeId"`
+	Route   Route     `json:"route"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+}
+
+// ItineraryDay holds the stops and legs that take place on one calendar day
+// in the trip's timezone. An item spanning several days, such as a hotel stay,
+// appears on each of them.
+type ItineraryDay struct {
+	Day   int             `json:"day"`  // 1-based day of the trip
+	Date  string          `json:"date"` // YYYY-MM-DD
+	Stops []ItineraryStop `json:"stops"`
+	Legs  []ItineraryLeg  `json:"legs"`
+}
+
+// Itinerary is a trip's schedule grouped by day. Issues lists any problems
+// found when validating the stored schedule against the trip's current data.
+type Itinerary struct {
+	TripID   int64          `json:"tripId"`
+	Timezone string         `json:"timezone"`
+	Days     []ItineraryDay `json:"days"`
+	Issues   []APIError     `json:"issues,omitempty"`
+}
+
+// BuildItinerary groups a trip's schedule into days, resolving location and
+// route references and converting times to the trip's timezone.
+func BuildItinerary(trip Trip, items []ScheduleItem, locations []TripLocation, routes []Route) (*Itinerary, error) {
+	tz, err := trip.Location()
+	if err != nil {
+		return nil, fmt.Errorf("trip %d timezone: %w", trip.ID, err)
+	}
+	start, err := time.ParseInLocation(tripDateLayout, trip.StartDate, tz)
+	if err != nil {
+		return nil, fmt.Errorf("trip %d start date: %w", trip.ID, err)
+	}
+	end, err := time.ParseInLocation(tripDateLayout, trip.EndDate, tz)
+	if err != nil {
+		return nil, fmt.Errorf("trip %d end date: %w", trip.ID, err)
+	}
+
+	itinerary := &Itinerary{
+		TripID:   trip.ID,
+		Timezone: trip.Timezone,
+		Days:     []ItineraryDay{},
+		Issues:   ValidateSchedule(trip, items, locations, routes),
+	}
+
+	var dayStarts []time.Time
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		dayStarts = append(dayStarts, day)
+		itinerary.Days = append(itinerary.Days, ItineraryDay{
+			Day:   len(itinerary.Days) + 1,
+			Date:  day.Format(tripDateLayout),
+			Stops: []ItineraryStop{},
+			Legs:  []ItineraryLeg{},
+		})
+	}
+
+	sorted := sortedSchedule(items)
+	for i, dayStart := range dayStarts {
+		dayEnd := dayStart.AddDate(0, 0, 1)
+		for _, item := range sorted {
+			// Items cover [Start, End); an instantaneous item belongs to the day it starts
+			itemEnd := item.End
+			if !itemEnd.After(item.Start) {
+				itemEnd = item.Start.Add(time.Nanosecond)
+			}
+			if !item.Start.Before(dayEnd) || !itemEnd.After(dayStart) {
+				continue // items outside the trip dates are reported by ValidateSchedule
+			}
+			addToDay(&itinerary.Days[i], item, locations, routes, tz)
+		}
+	}
+
+	return itinerary, nil
+}
+
+// addToDay appends a schedule item to a day as a stop or leg
+func addToDay(day *ItineraryDay, item ScheduleItem, locations []TripLocation, routes []Route, tz *time.Location) {
+	switch item.Kind {
+	case ScheduleKindStop:
+		loc, _ := findLocation(locations, item.LocationID)
+		day.Stops = append(day.Stops, ItineraryStop{
+			LocationID: item.LocationID,
+			Location:   loc,
+			Start:      item.Start.In(tz),
+			End:        item.End.In(tz),
+		})
+	case ScheduleKindLeg:
+		route, _ := findRoute(routes, item.RouteID)
+		day.Legs = append(day.Legs, ItineraryLeg{
+			RouteID: item.RouteID,
+			Route:   route,
+			Start:   item.Start.In(tz),
+			End:     item.End.In(tz),
+		})
+	}
+}
+
+// ValidateSchedule checks that every item references a location or route of the
+// trip, falls within the trip dates, does not overlap any earlier item, and that
+// each leg starts where the previous item ends and ends where the next item starts.
+// Fields are reported as items[i].<field> using the item's index in items.
+func ValidateSchedule(trip Trip, items []ScheduleItem, locations []TripLocation, routes []Route) []APIError {
+	var errs []APIError
+	issue := func(code string, i int, field, format string, args ...any) {
+		errs = append(errs, APIError{
+			Code:    code,
+			Field:   fmt.Sprintf("items[%d].%s", i, field),
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	tz, err := trip.Location()
+	if err != nil {
+		return []APIError{{Code: ErrCodeInvalidValue, Field: "timezone", Message: err.Error()}}
+	}
+
+	// Check in chronological order, remembering each item's index in items
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return items[a].Start.Compare(items[b].Start)
+	})
+
+	// endpoints returns where an item begins and ends, if it resolves
+	type point struct{ lat, lng float64 }
+	endpoints := func(item ScheduleItem) (from, to point, ok bool) {
+		switch item.Kind {
+		case ScheduleKindStop:
+			loc, found := findLocation(locations, item.LocationID)
+			p := point{loc.Lat, loc.Lng}
+			return p, p, found
+		case ScheduleKindLeg:
+			route, found := findRoute(routes, item.RouteID)
+			return point{route.Origin.Lat, route.Origin.Lng}, point{route.Destination.Lat, route.Destination.Lng}, found
+		}
+		return point{}, point{}, false
+	}
+	near := func(a, b point) bool {
+		return haversineKm(a.lat, a.lng, b.lat, b.lng) <= connectionToleranceKm
+	}
+
+	var latest *ScheduleItem // the earlier item that ends last
+	for n, i := range order {
+		item := items[i]
+		switch item.Kind {
+		case ScheduleKindStop:
+			if _, ok := findLocation(locations, item.LocationID); !ok {
+				issue(ErrCodeInvalidValue, i, "locationId", "location %d is not part of this trip", item.LocationID)
+			}
+		case ScheduleKindLeg:
+			if _, ok := findRoute(routes, item.RouteID); !ok {
+				issue(ErrCodeInvalidValue, i, "routeId", "route %d is not part of this trip", item.RouteID)
+			}
+		default:
+			issue(ErrCodeInvalidValue, i, "kind", "kind must be %q or %q", ScheduleKindStop, ScheduleKindLeg)
+			continue
+		}
+
+		if item.Start.IsZero() || item.End.IsZero() {
+			issue(ErrCodeRequired, i, "start", "start and end are required")
+			continue
+		}
+		if item.End.Before(item.Start) {
+			issue(ErrCodeOutOfRange, i, "end", "end must not be before start")
+		}
+		if date := item.Start.In(tz).Format(tripDateLayout); date < trip.StartDate {
+			issue(ErrCodeOutOfRange, i, "start", "starts on %s, before the trip begins on %s", date, trip.StartDate)
+		}
+		if date := item.End.In(tz).Format(tripDateLayout); date > trip.EndDate {
+			issue(ErrCodeOutOfRange, i, "end", "ends on %s, after the trip ends on %s", date, trip.EndDate)
+		}
+
+		// Compare with the latest end so far, not just the previous item, so an
+		// item inside a long stay is caught even after a shorter overlapping one
+		if latest != nil && item.Start.Before(latest.End) {
+			issue(ErrCodeOutOfRange, i, "start", "starts at %s, before the earlier %s ends at %s",
+				item.Start.In(tz).Format(time.RFC3339), latest.Kind, latest.End.In(tz).Format(time.RFC3339))
+		}
+		if latest == nil || item.End.After(latest.End) {
+			latest = &items[i]
+		}
+
+		if n == 0 {
+			continue
+		}
+		prev := items[order[n-1]]
+
+		// Legs must connect to their neighbors; consecutive stops may be far apart
+		if item.Kind != ScheduleKindLeg && prev.Kind != ScheduleKindLeg {
+			continue
+		}
+		_, prevTo, prevOK := endpoints(prev)
+		from, _, ok := endpoints(item)
+		if prevOK && ok && !near(prevTo, from) {
+			field := "locationId"
+			if item.Kind == ScheduleKindLeg {
+				field = "routeId"
+			}
+			issue(ErrCodeInvalidValue, i, field, "%s does not start where the previous %s ends", item.Kind, prev.Kind)
+		}
+	}
+
+	return errs
+}
+
+// sortedSchedule returns a copy of items ordered by start time
+func sortedSchedule(items []ScheduleItem) []ScheduleItem {
+	sorted := slices.Clone(items)
+	slices.SortStableFunc(sorted, func(a, b ScheduleItem) int {
+		return a.Start.Compare(b.Start)
+	})
+	return sorted
+}
+
+func findLocation(locations []TripLocation, id int64) (TripLocation, bool) {
+	for _, loc := range locations {
+		if loc.ID == id {
+			return loc, true
+		}
+	}
+	return TripLocation{}, false
+}
+
+func findRoute(routes []Route, id int64) (Route, bool) {
+	for _, route := range routes {
+		if route.ID == id {
+			return route, true
+		}
+	}
+	return Route{}, false
+}
+
+// jst is Japan Standard Time; Japan does not observe daylight saving time
+var jst = time.FixedZone("JST", 9*60*60)
+
+// jstTime parses a "2006-01-02 15:04" wall-clock time in Japan Standard Time
+func jstTime(value string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", value, jst)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// TripSchedule is the seed schedule for DefaultTrip. IDs refer to the seeded
+// TripLocations and TripRoutes in declaration order.
+var TripSchedule = []ScheduleItem{
+	// Day 1: arrive at Haneda, transfer to Shinjuku
+	{Kind: ScheduleKindStop, LocationID: 4, Start: jstTime("2025-11-03 14:00"), End: jstTime("2025-11-03 14:30")},
+	{Kind: ScheduleKindLeg, RouteID: 3, Start: jstTime("2025-11-03 14:30"), End: jstTime("2025-11-03 15:05")},
+	{Kind: ScheduleKindStop, LocationID: 1, Start: jstTime("2025-11-03 15:05"), End: jstTime("2025-11-06 08:30")},
+	// Day 4: Shinkansen to Kyoto
+	{Kind: ScheduleKindStop, LocationID: 6, Start: jstTime("2025-11-06 09:00"), End: jstTime("2025-11-06 09:30")},
+	{Kind: ScheduleKindLeg, RouteID: 1, Start: jstTime("2025-11-06 09:30"), End: jstTime("2025-11-06 11:50")},
+	{Kind: ScheduleKindStop, LocationID: 7, Start: jstTime("2025-11-06 11:50"), End: jstTime("2025-11-06 12:10")},
+	{Kind: ScheduleKindStop, LocationID: 2, Start: jstTime("2025-11-06 15:00"), End: jstTime("2025-11-08 11:00")},
+	// Day 6: transfer to Osaka
+	{Kind: ScheduleKindLeg, RouteID: 2, Start: jstTime("2025-11-08 11:00"), End: jstTime("2025-11-08 12:00")},
+	{Kind: ScheduleKindStop, LocationID: 3, Start: jstTime("2025-11-08 12:00"), End: jstTime("2025-11-10 09:00")},
+	// Day 8: fly home from Itami
+	{Kind: ScheduleKindLeg, RouteID: 4, Start: jstTime("2025-11-10 09:00"), End: jstTime("2025-11-10 09:25")},
+	{Kind: ScheduleKindStop, LocationID: 5, Start: jstTime("2025-11-10 09:25"), End: jstTime("2025-11-10 10:30")},
+}
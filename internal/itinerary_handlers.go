@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// handleItinerary serves /api/[trips/{trip}/]itinerary:
+// GET returns the day-by-day itinerary, PUT replaces the trip's schedule.
+func (s *Server) handleItinerary(w http.ResponseWriter, r *http.Request) {
+	trip, ok := s.tripFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		items, err := s.Store.Schedule(r.Context(), trip.ID)
+		if err != nil {
+			writeStoreError(w, err, "schedule", trip.ID)
+			return
+		}
+		s.writeItinerary(w, r, trip, items)
+	case http.MethodPut:
+		var req ScheduleRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		locations, routes, ok := s.tripPlaces(w, r, trip)
+		if !ok {
+			return
+		}
+		if !checkValidation(w, "itinerary", ValidateSchedule(trip, req.Items, locations, routes)) {
+			return
+		}
+
+		if err := s.Store.ReplaceSchedule(r.Context(), trip.ID, req.Items); err != nil {
+			writeStoreError(w, err, "schedule", trip.ID)
+			return
+		}
+		s.writeItinerary(w, r, trip, req.Items)
+	default:
+		methodNotAllowed(w, "GET, PUT, OPTIONS")
+	}
+}
+
+// writeItinerary builds and writes the itinerary for the given schedule
+func (s *Server) writeItinerary(w http.ResponseWriter, r *http.Request, trip Trip, items []ScheduleItem) {
+	locations, routes, ok := s.tripPlaces(w, r, trip)
+	if !ok {
+		return
+	}
+
+	itinerary, err := BuildItinerary(trip, items, locations, routes)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, APIError{
+			Code:    ErrCodeInternal,
+			Message: fmt.Sprintf("Error building itinerary: %v", err),
+		})
+		return
+	}
+
+	okJSON(w, itinerary)
+}
+
+// tripPlaces loads the locations and routes a schedule can reference
+func (s *Server) tripPlaces(w http.ResponseWriter, r *http.Request, trip Trip) ([]TripLocation, []Route, bool) {
+	locations, err := s.Store.Locations(r.Context(), trip.ID)
+	if err != nil {
+		writeStoreError(w, err, "locations", trip.ID)
+		return nil, nil, false
+	}
+	routes, err := s.Store.Routes(r.Context(), trip.ID)
+	if err != nil {
+		writeStoreError(w, err, "routes", trip.ID)
+		return nil, nil, false
+	}
+	return locations, routes, true
+}
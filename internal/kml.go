@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// kmlIcons maps location types to Google Earth icon shapes
+var kmlIcons = map[LocationType]string{
+	LocationTypeHotel:      "https://maps.google.com/mapfiles/kml/shapes/lodging.png",
+	LocationTypeAirport:    "https://maps.google.com/mapfiles/kml/shapes/airports.png",
+	LocationTypeStation:    "https://maps.google.com/mapfiles/kml/shapes/rail.png",
+	LocationTypeRestaurant: "https://maps.google.com/mapfiles/kml/shapes/dining.png",
+	LocationTypeSight:      "https://maps.google.com/mapfiles/kml/shapes/camera.png",
+}
+
+// kmlDefaultIcon is used for location types without a dedicated icon
+const kmlDefaultIcon = "https://maps.google.com/mapfiles/kml/paddle/wht-blank.png"
+
+// RouteTypeColors maps route types to their display colors (matches the frontend palette)
+var RouteTypeColors = map[string]string{
+	"train":  "#a78bfa",
+	"car":    "#60a5fa",
+	"walk":   "#34d399",
+	"flight": "#f87171",
+}
+
+// defaultDisplayColor is used when a city or route type has no color
+const defaultDisplayColor = "#94a3b8"
+
+// KML 2.2 document (https://developers.google.com/kml/documentation/kmlreference)
+type kmlDocument struct {
+	XMLName  xml.Name    `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Document kmlContents `xml:"Document"`
+}
+
+type kmlContents struct {
+	Name    string      `xml:"name"`
+	Styles  []kmlStyle  `xml:"Style"`
+	Folders []kmlFolder `xml:"Folder"`
+}
+
+type kmlStyle struct {
+	ID        string        `xml:"id,attr"`
+	IconStyle *kmlIconStyle `xml:"IconStyle,omitempty"`
+	LineStyle *kmlLineStyle `xml:"LineStyle,omitempty"`
+}
+
+type kmlIconStyle struct {
+	Color string  `xml:"color"`
+	Icon  kmlIcon `xml:"Icon"`
+}
+
+type kmlIcon struct {
+	Href string `xml:"href"`
+}
+
+type kmlLineStyle struct {
+	Color string  `xml:"color"`
+	Width float64 `xml:"width"`
+}
+
+type kmlFolder struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name        string         `xml:"name"`
+	Description string         `xml:"description,omitempty"`
+	StyleURL    string         `xml:"styleUrl"`
+	Point       *kmlPoint      `xml:"Point,omitempty"`
+	LineString  *kmlLineString `xml:"LineString,omitempty"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlLineString struct {
+	Tessellate  int    `xml:"tessellate"`
+	Coordinates string `xml:"coordinates"`
+}
+
+// WriteKML writes the trip as KML 2.2 with a Locations folder and a Routes folder.
+// Location placemarks are styled by city color and location type icon; route lines
+// are styled by route type.
+func WriteKML(w io.Writer, trip Trip, cities []City, locations []TripLocation, routes []CachedRoute) error {
+	cityColors := make(map[string]string, len(cities))
+	for _, city := range cities {
+		cityColors[city.Name] = city.Color
+	}
+
+	// Style IDs refer to cities by number, since names such as 京都 have no
+	// characters an ID can keep. Cities a location names but the trip does
+	// not list are numbered after the listed ones.
+	cityKeys := make(map[string]string, len(cities))
+	cityKey := func(name string) string {
+		key, ok := cityKeys[name]
+		if !ok {
+			key = "city" + strconv.Itoa(len(cityKeys)+1)
+			cityKeys[name] = key
+		}
+		return key
+	}
+	for _, city := range cities {
+		cityKey(city.Name)
+	}
+
+	doc := kmlDocument{Document: kmlContents{Name: trip.Name}}
+	styles := make(map[string]bool)
+
+	locationFolder := kmlFolder{Name: "Locations"}
+	for _, loc := range locations {
+		styleID := kmlStyleID("location", cityKey(loc.City), string(loc.Type))
+		if !styles[styleID] {
+			styles[styleID] = true
+			icon := kmlIcons[loc.Type]
+			if icon == "" {
+				icon = kmlDefaultIcon
+			}
+			doc.Document.Styles = append(doc.Document.Styles, kmlStyle{
+				ID: styleID,
+				IconStyle: &kmlIconStyle{
+					Color: kmlColor(cityColors[loc.City]),
+					Icon:  kmlIcon{Href: icon},
+				},
+			})
+		}
+
+		locationFolder.Placemarks = append(locationFolder.Placemarks, kmlPlacemark{
+			Name:        loc.Name,
+			Description: fmt.Sprintf("%s · %s", loc.Type, loc.City),
+			StyleURL:    "#" + styleID,
+			Point:       &kmlPoint{Coordinates: kmlCoordinate(loc.Lng, loc.Lat)},
+		})
+	}
+
+	routeFolder := kmlFolder{Name: "Routes"}
+	for _, route := range routes {
+		styleID := kmlStyleID("route", route.Type)
+		if !styles[styleID] {
+			styles[styleID] = true
+			doc.Document.Styles = append(doc.Document.Styles, kmlStyle{
+				ID:        styleID,
+				LineStyle: &kmlLineStyle{Color: kmlColor(RouteTypeColors[route.Type]), Width: 4},
+			})
+		}
+
+		coords := make([]string, len(route.Geometry))
+		for i, coord := range route.Geometry {
+			coords[i] = kmlCoordinate(coord[0], coord[1])
+		}
+
+		routeFolder.Placemarks = append(routeFolder.Placemarks, kmlPlacemark{
+			Name: route.Name,
+			Description: fmt.Sprintf("%s to %s · %.1f km · %.0f min",
+				route.Origin.Name, route.Destination.Name, route.Distance/1000, route.Duration/60),
+			StyleURL:   "#" + styleID,
+			LineString: &kmlLineString{Tessellate: 1, Coordinates: strings.Join(coords, " ")},
+		})
+	}
+
+	doc.Document.Folders = []kmlFolder{locationFolder, routeFolder}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// kmlStyleID builds a style ID from its parts, e.g. "location-city1-hotel"
+func kmlStyleID(parts ...string) string {
+	return strings.Trim(nonFilenameChars.ReplaceAllString(strings.ToLower(strings.Join(parts, "-")), "-"), "-")
+}
+
+// kmlColor converts a #rrggbb color to KML's opaque aabbggrr form
+func kmlColor(hex string) string {
+	if !colorPattern.MatchString(hex) {
+		hex = defaultDisplayColor
+	}
+	r, g, b := hex[1:3], hex[3:5], hex[5:7]
+	return strings.ToLower("ff" + b + g + r)
+}
+
+// kmlCoordinate formats a lng,lat tuple
+func kmlCoordinate(lng, lat float64) string {
+	return strconv.FormatFloat(lng, 'f', -1, 64) + "," + strconv.FormatFloat(lat, 'f', -1, 64)
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/xml"
+	"regexp"
+	"testing"
+)
+
+func TestKMLStyleIDsForNonASCIICities(t *testing.T) {
+	cities := []City{
+		{Name: "京都", Color: "#ff0000"},
+		{Name: "大阪", Color: "#00ff00"},
+	}
+	locations := []TripLocation{
+		{Name: "Kiyomizu-dera", Type: LocationTypeSight, City: "京都"},
+		{Name: "Dotonbori", Type: LocationTypeSight, City: "大阪"},
+		{Name: "Itsukushima", Type: LocationTypeSight, City: "宮島"}, // not a trip city
+	}
+
+	var buf bytes.Buffer
+	if err := WriteKML(&buf, Trip{Name: "Kansai"}, cities, locations, nil); err != nil {
+		t.Fatal(err)
+	}
+	var doc kmlDocument
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	idPattern := regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+	styles := map[string]kmlStyle{}
+	for _, style := range doc.Document.Styles {
+		if !idPattern.MatchString(style.ID) {
+			t.Errorf("style ID %q is not a valid ID", style.ID)
+		}
+		styles[style.ID] = style
+	}
+	if len(styles) != len(locations) {
+		t.Fatalf("%d distinct styles, want one per city: %v", len(styles), doc.Document.Styles)
+	}
+
+	placemarks := doc.Document.Folders[0].Placemarks
+	wantColors := []string{"ff0000ff", "ff00ff00", kmlColor(defaultDisplayColor)}
+	for i, p := range placemarks {
+		style, ok := styles[p.StyleURL[1:]]
+		if !ok {
+			t.Errorf("%s uses missing style %s", p.Name, p.StyleURL)
+			continue
+		}
+		if style.IconStyle.Color != wantColors[i] {
+			t.Errorf("%s color = %s, want %s", p.Name, style.IconStyle.Color, wantColors[i])
+		}
+	}
+}
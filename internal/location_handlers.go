@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// maxRequestBodyBytes limits the size of JSON request bodies
+const maxRequestBodyBytes = 1 << 20
+
+// handleLocationsCollection serves /api/[trips/{trip}/]locations:
+// GET lists locations as GeoJSON, POST creates a new location.
+func (s *Server) handleLocationsCollection(w http.ResponseWriter, r *http.Request) {
+	trip, ok := s.tripFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.handleLocations(w, r, trip)
+	case http.MethodPost:
+		s.handleCreateLocation(w, r, trip)
+	default:
+		methodNotAllowed(w, "GET, POST, OPTIONS")
+	}
+}
+
+// handleLocationItem serves /api/[trips/{trip}/]locations/{id}:
+// GET returns the location, PUT replaces it, PATCH updates some fields, DELETE removes it.
+func (s *Server) handleLocationItem(w http.ResponseWriter, r *http.Request) {
+	trip, ok := s.tripFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, APIError{
+			Code:    ErrCodeInvalidID,
+			Field:   "id",
+			Message: fmt.Sprintf("invalid location id %q", r.PathValue("id")),
+		})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		loc, err := s.Store.Location(r.Context(), trip.ID, id)
+		if err != nil {
+			writeStoreError(w, err, "location", id)
+			return
+		}
+		okJSON(w, loc)
+	case http.MethodPut:
+		s.handleReplaceLocation(w, r, trip, id)
+	case http.MethodPatch:
+		s.handlePatchLocation(w, r, trip, id)
+	case http.MethodDelete:
+		if err := s.Store.DeleteLocation(r.Context(), trip.ID, id); err != nil {
+			writeStoreError(w, err, "location", id)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, "GET, PUT, PATCH, DELETE, OPTIONS")
+	}
+}
+
+// handleCreateLocation validates and stores a new location
+func (s *Server) handleCreateLocation(w http.ResponseWriter, r *http.Request, trip Trip) {
+	var req TripLocationRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	loc := req.Location()
+	loc.TripID = trip.ID
+
+	if !s.validateLocation(w, r, loc, req.MissingCoordinates()...) {
+		return
+	}
+
+	created, err := s.Store.CreateLocation(r.Context(), loc)
+	if err != nil {
+		writeStoreError(w, err, "location", 0)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%d", r.URL.Path, created.ID))
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// handleReplaceLocation replaces every field of an existing location
+func (s *Server) handleReplaceLocation(w http.ResponseWriter, r *http.Request, trip Trip, id int64) {
+	var req TripLocationRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	loc := req.Location()
+	loc.ID = id
+	loc.TripID = trip.ID
+
+	if !s.validateLocation(w, r, loc, req.MissingCoordinates()...) {
+		return
+	}
+
+	updated, err := s.Store.UpdateLocation(r.Context(), loc)
+	if err != nil {
+		writeStoreError(w, err, "location", id)
+		return
+	}
+
+	okJSON(w, updated)
+}
+
+// handlePatchLocation applies a partial update to an existing location
+func (s *Server) handlePatchLocation(w http.ResponseWriter, r *http.Request, trip Trip, id int64) {
+	var patch TripLocationPatch
+	if !decodeJSONBody(w, r, &patch) {
+		return
+	}
+
+	existing, err := s.Store.Location(r.Context(), trip.ID, id)
+	if err != nil {
+		writeStoreError(w, err, "location", id)
+		return
+	}
+
+	loc := patch.Apply(existing)
+	if !s.validateLocation(w, r, loc) {
+		return
+	}
+
+	updated, err := s.Store.UpdateLocation(r.Context(), loc)
+	if err != nil {
+		writeStoreError(w, err, "location", id)
+		return
+	}
+
+	okJSON(w, updated)
+}
+
+// validateLocation writes a 422 response and returns false if loc is invalid,
+// its city does not belong to the location's trip, or errs (problems already
+// found in the request body) is not empty
+func (s *Server) validateLocation(w http.ResponseWriter, r *http.Request, loc TripLocation, errs ...APIError) bool {
+	cities, err := s.Store.Cities(r.Context(), loc.TripID)
+	if err != nil {
+		writeStoreError(w, err, "cities", 0)
+		return false
+	}
+
+	return checkValidation(w, "location", append(errs, ValidateLocation(loc, cities)...))
+}
+
+// checkValidation writes a 422 response listing errs and returns false,
+// or returns true when there are no validation errors
+func checkValidation(w http.ResponseWriter, resource string, errs []APIError) bool {
+	if len(errs) == 0 {
+		return true
+	}
+
+	apiErr := APIError{
+		Code:    ErrCodeValidationFailed,
+		Message: resource + " is invalid",
+		Details: errs,
+	}
+	if len(errs) == 1 {
+		apiErr.Field = errs[0].Field
+		apiErr.Message = errs[0].Message
+	}
+	writeError(w, http.StatusUnprocessableEntity, apiErr)
+	return false
+}
+
+// decodeJSONBody decodes the request body into v, rejecting unknown fields.
+// On failure it writes a 400 response and returns false.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, APIError{
+			Code:    ErrCodeInvalidBody,
+			Message: fmt.Sprintf("invalid JSON body: %v", err),
+		})
+		return false
+	}
+	return true
+}
+
+// writeStoreError maps a TripStore error to a 404, 409 or 500 response. A 409
+// for a record the itinerary still uses lists the referring entries in Details.
+func writeStoreError(w http.ResponseWriter, err error, resource string, id int64) {
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, APIError{
+			Code:    ErrCodeNotFound,
+			Message: fmt.Sprintf("%s %d not found", resource, id),
+		})
+		return
+	}
+	var refErr *ReferencedError
+	if errors.As(err, &refErr) {
+		writeError(w, http.StatusConflict, APIError{
+			Code:    ErrCodeConflict,
+			Message: fmt.Sprintf("%s %d is %s; remove them from the itinerary first", resource, id, refErr),
+			Details: refErr.Items,
+		})
+		return
+	}
+	writeError(w, http.StatusInternalServerError, APIError{
+		Code:    ErrCodeInternal,
+		Message: fmt.Sprintf("Error accessing %s: %v", resource, err),
+	})
+}
+
+// methodNotAllowed writes a 405 response listing the allowed methods
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	writeError(w, http.StatusMethodNotAllowed, APIError{
+		Code:    ErrCodeMethodNotAllowed,
+		Message: fmt.Sprintf("method not allowed, use one of: %s", allow),
+	})
+}
@@ -1,6 +1,9 @@
 package internal
 
 import (
+	"fmt"
+	"slices"
+
 	"github.com/uber/h3-go/v4"
 )
 
@@ -8,21 +11,134 @@ import (
 type LocationType string
 
 const (
-	LocationTypeHotel   LocationType = "hotel"
-	LocationTypeAirport LocationType = "airport"
-	LocationTypeStation LocationType = "station"
+	LocationTypeHotel      LocationType = "hotel"
+	LocationTypeAirport    LocationType = "airport"
+	LocationTypeStation    LocationType = "station"
+	LocationTypeRestaurant LocationType = "restaurant"
+	LocationTypeSight      LocationType = "sight"
 )
 
+// LocationTypes lists every known LocationType
+var LocationTypes = []LocationType{
+	LocationTypeHotel,
+	LocationTypeAirport,
+	LocationTypeStation,
+	LocationTypeRestaurant,
+	LocationTypeSight,
+}
+
 // TripLocation represents a point of interest in the trip
 type TripLocation struct {
-	Name string       `json:"name"`
-	Type LocationType `json:"type"`
-	City string       `json:"city"`
-	Lat  float64      `json:"lat"`
-	Lng  float64      `json:"lng"`
+	ID     int64        `json:"id"`
+	TripID int64        `json:"tripId"`
+	Name   string       `json:"name"`
+	Type   LocationType `json:"type"`
+	City   string       `json:"city"`
+	Lat    float64      `json:"lat"`
+	Lng    float64      `json:"lng"`
+}
+
+// TripLocationRequest is the body of a location create or full replace.
+// Coordinates are pointers so that a missing lat or lng is reported instead of
+// placing the location at 0.
+type TripLocationRequest struct {
+	ID     int64        `json:"id"`     // ignored; the ID comes from the URL
+	TripID int64        `json:"tripId"` // ignored; the trip comes from the URL
+	Name   string       `json:"name"`
+	Type   LocationType `json:"type"`
+	City   string       `json:"city"`
+	Lat    *float64     `json:"lat"`
+	Lng    *float64     `json:"lng"`
+}
+
+// Location returns the requested location, with 0 for missing coordinates
+func (req TripLocationRequest) Location() TripLocation {
+	loc := TripLocation{Name: req.Name, Type: req.Type, City: req.City}
+	if req.Lat != nil {
+		loc.Lat = *req.Lat
+	}
+	if req.Lng != nil {
+		loc.Lng = *req.Lng
+	}
+	return loc
+}
+
+// MissingCoordinates returns an APIError for each coordinate absent from the request
+func (req TripLocationRequest) MissingCoordinates() []APIError {
+	var errs []APIError
+	if req.Lat == nil {
+		errs = append(errs, APIError{Code: ErrCodeRequired, Field: "lat", Message: "lat is required"})
+	}
+	if req.Lng == nil {
+		errs = append(errs, APIError{Code: ErrCodeRequired, Field: "lng", Message: "lng is required"})
+	}
+	return errs
+}
+
+// TripLocationPatch holds the fields of a partial TripLocation update.
+// Nil fields are left unchanged.
+type TripLocationPatch struct {
+	Name *string       `json:"name"`
+	Type *LocationType `json:"type"`
+	City *string       `json:"city"`
+	Lat  *float64      `json:"lat"`
+	Lng  *float64      `json:"lng"`
+}
+
+// Apply returns loc with the non-nil patch fields applied
+func (p TripLocationPatch) Apply(loc TripLocation) TripLocation {
+	if p.Name != nil {
+		loc.Name = *p.Name
+	}
+	if p.Type != nil {
+		loc.Type = *p.Type
+	}
+	if p.City != nil {
+		loc.City = *p.City
+	}
+	if p.Lat != nil {
+		loc.Lat = *p.Lat
+	}
+	if p.Lng != nil {
+		loc.Lng = *p.Lng
+	}
+	return loc
+}
+
+// ValidateLocation checks a location against the known location types and cities.
+// It returns one APIError per invalid field, or nil if the location is valid.
+func ValidateLocation(loc TripLocation, cities []City) []APIError {
+	var errs []APIError
+
+	if loc.Name == "" {
+		errs = append(errs, APIError{Code: ErrCodeRequired, Field: "name", Message: "name is required"})
+	}
+	if !slices.Contains(LocationTypes, loc.Type) {
+		errs = append(errs, APIError{
+			Code:    ErrCodeInvalidValue,
+			Field:   "type",
+			Message: fmt.Sprintf("unknown location type %q", loc.Type),
+			Details: LocationTypes,
+		})
+	}
+	if !slices.ContainsFunc(cities, func(c City) bool { return c.Name == loc.City }) {
+		errs = append(errs, APIError{
+			Code:    ErrCodeInvalidValue,
+			Field:   "city",
+			Message: fmt.Sprintf("unknown city %q", loc.City),
+		})
+	}
+	if loc.Lat < -90 || loc.Lat > 90 {
+		errs = append(errs, APIError{Code: ErrCodeOutOfRange, Field: "lat", Message: "lat must be between -90 and 90"})
+	}
+	if loc.Lng < -180 || loc.Lng > 180 {
+		errs = append(errs, APIError{Code: ErrCodeOutOfRange, Field: "lng", Message: "lng must be between -180 and 180"})
+	}
+
+	return errs
 }
 
-// TripLocations contains all locations for the Japan trip
+// TripLocations is the seed data for the trip store's locations
 var TripLocations = []TripLocation{
 	// Hotels
 	{
@@ -79,10 +195,10 @@ var TripLocations = []TripLocation{
 }
 
 // GetLocationsGeoJSON returns locations as GeoJSON points
-func GetLocationsGeoJSON(resolution int) (*GeoJSON, error) {
+func GetLocationsGeoJSON(locations []TripLocation, resolution int) (*GeoJSON, error) {
 	features := []Feature{}
 
-	for _, loc := range TripLocations {
+	for _, loc := range locations {
 		// Convert to H3 cell
 		latLng := h3.LatLng{Lat: loc.Lat, Lng: loc.Lng}
 		cell, err := h3.LatLngToCell(latLng, resolution)
@@ -103,6 +219,7 @@ func GetLocationsGeoJSON(resolution int) (*GeoJSON, error) {
 				Coordinates: []float64{center.Lng, center.Lat},
 			},
 			Properties: map[string]any{
+				"id":         loc.ID,
 				"name":       loc.Name,
 				"type":       string(loc.Type),
 				"city":       loc.City,
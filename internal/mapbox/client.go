@@ -0,0 +1,205 @@
+// Package mapbox is a small client for the Mapbox Directions and Geocoding APIs
+// with timeouts, context cancellation and retries on rate limits and server errors.
+package mapbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultBaseURL is the public Mapbox API host
+const DefaultBaseURL = "https://api.mapbox.com"
+
+const (
+	defaultTimeout      = 10 * time.Second
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 500 * time.Millisecond
+	defaultMaxRetryWait = 10 * time.Second
+	maxResponseBytes    = 10 << 20
+)
+
+// ErrNoToken is returned when the client has no access token
+var ErrNoToken = errors.New("mapbox: access token not configured")
+
+// Client calls the Mapbox APIs. BaseURL and HTTPClient can be replaced, for
+// example to point at a fake server or a proxy; API paths are appended to any
+// path BaseURL already has.
+type Client struct {
+	BaseURL      string
+	Token        string
+	HTTPClient   *http.Client
+	MaxRetries   int           // retries after the first attempt on 429, 5xx and network errors
+	RetryBackoff time.Duration // wait before the first retry; doubles on each further retry
+	MaxRetryWait time.Duration // longest single wait, including a server's Retry-After; 0 means 10s
+}
+
+// New returns a client for the public API with default timeouts and retries
+func New(token string) *Client {
+	return &Client{
+		BaseURL:      DefaultBaseURL,
+		Token:        token,
+		HTTPClient:   &http.Client{Timeout: defaultTimeout},
+		MaxRetries:   defaultMaxRetries,
+		RetryBackoff: defaultRetryBackoff,
+		MaxRetryWait: defaultMaxRetryWait,
+	}
+}
+
+// Error is a non-2xx response from Mapbox
+type Error struct {
+	StatusCode int
+	Code       string // Mapbox error code, e.g. "InvalidInput" or "NoRoute"
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("mapbox: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("mapbox: %d: %s", e.StatusCode, e.Message)
+}
+
+// retryable reports whether a response status is worth retrying
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// get requests path with query (plus the access token), decodes a JSON
+// response into v and returns the body as received. escapedPath must already
+// be escaped.
+func (c *Client) get(ctx context.Context, escapedPath string, query url.Values, v any) ([]byte, error) {
+	if c.Token == "" {
+		return nil, ErrNoToken
+	}
+
+	base, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("mapbox: invalid base URL: %w", err)
+	}
+	endpoint := base.JoinPath(escapedPath)
+	// Logged and returned errors use the URL without the token
+	displayURL := endpoint.String()
+
+	q := url.Values{}
+	for k, vs := range query {
+		q[k] = vs
+	}
+	q.Set("access_token", c.Token)
+	endpoint.RawQuery = q.Encode()
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	maxWait := c.MaxRetryWait
+	if maxWait <= 0 {
+		maxWait = defaultMaxRetryWait
+	}
+
+	backoff := c.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		body, status, retryAfter, err := c.attempt(ctx, httpClient, endpoint.String())
+		if err == nil && status >= 200 && status < 300 {
+			if err := json.Unmarshal(body, v); err != nil {
+				return nil, fmt.Errorf("mapbox: decoding response from %s: %w", displayURL, err)
+			}
+			return body, nil
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			err = fmt.Errorf("mapbox: GET %s: %w", displayURL, err)
+		} else {
+			err = responseError(status, body)
+			if !retryable(status) {
+				return nil, err
+			}
+		}
+
+		if attempt >= c.MaxRetries {
+			return nil, err
+		}
+
+		wait := backoff
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		if wait > maxWait {
+			wait = maxWait
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// attempt makes one request, returning the body, status and any Retry-After delay.
+// Transport errors are returned with the URL stripped, since it holds the token.
+func (c *Client) attempt(ctx context.Context, httpClient *http.Client, rawURL string) ([]byte, int, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	return body, resp.StatusCode, parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()), nil
+}
+
+// parseRetryAfter reads a Retry-After header given as delay seconds or an HTTP
+// date, returning 0 if it is missing, invalid or already past
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// responseError builds an Error from a non-2xx response body
+func responseError(status int, body []byte) error {
+	var payload struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(body, &payload)
+	if payload.Message == "" {
+		payload.Message = http.StatusText(status)
+	}
+	return &Error{StatusCode: status, Code: payload.Code, Message: payload.Message}
+}
@@ -0,0 +1,349 @@
+package mapbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testToken = "pk.test-secret-token"
+
+// newTestClient returns a client pointed at handler with fast retries
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	c := New(testToken)
+	c.BaseURL = srv.URL
+	c.RetryBackoff = time.Millisecond
+	c.MaxRetryWait = time.Second
+	return c
+}
+
+// failing responds with status and a Mapbox error body for the first n requests,
+// then with body; it counts every request in calls
+func failing(n int32, status int, header http.Header, body string, calls *atomic.Int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= n {
+			for k, vs := range header {
+				w.Header()[k] = vs
+			}
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"code":"Busy","message":"try again"}`)
+			return
+		}
+		fmt.Fprint(w, body)
+	}
+}
+
+const directionsBody = `{
+	"code": "Ok",
+	"routes": [{
+		"geometry": {"type": "LineString", "coordinates": [[139.76, 35.68], [135.50, 34.69]]},
+		"distance": 503123.4,
+		"duration": 21600.5,
+		"weight": 22000,
+		"weight_name": "auto",
+		"legs": [{"summary": "Tomei"}]
+	}],
+	"waypoints": [{"name": "Tokyo"}, {"name": "Osaka"}],
+	"uuid": "abc"
+}`
+
+var tokyoOsaka = []LngLat{{Lng: 139.76, Lat: 35.68}, {Lng: 135.5, Lat: 34.69}}
+
+func TestRetriesOnRateLimitAndServerErrors(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			var calls atomic.Int32
+			c := newTestClient(t, failing(2, status, nil, directionsBody, &calls))
+
+			resp, err := c.Directions(context.Background(), DirectionsRequest{Coordinates: tokyoOsaka})
+			if err != nil {
+				t.Fatalf("Directions: %v", err)
+			}
+			if resp.Code != "Ok" {
+				t.Errorf("code = %q, want Ok", resp.Code)
+			}
+			if got := calls.Load(); got != 3 {
+				t.Errorf("requests = %d, want 3", got)
+			}
+		})
+	}
+}
+
+func TestGivesUpAfterMaxRetries(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, failing(100, http.StatusServiceUnavailable, nil, directionsBody, &calls))
+	c.MaxRetries = 2
+
+	_, err := c.Directions(context.Background(), DirectionsRequest{Coordinates: tokyoOsaka})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *Error", err)
+	}
+	if apiErr.StatusCode != http.StatusServiceUnavailable || apiErr.Code != "Busy" || apiErr.Message != "try again" {
+		t.Errorf("err = %+v", apiErr)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		fmt.Fprint(w, `{"code":"InvalidInput","message":"Coordinate is invalid"}`)
+	})
+
+	_, err := c.Directions(context.Background(), DirectionsRequest{Coordinates: tokyoOsaka})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity || apiErr.Code != "InvalidInput" {
+		t.Fatalf("err = %v, want 422 InvalidInput", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestRetryAfterOverridesBackoff(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, failing(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}}, directionsBody, &calls))
+	c.MaxRetryWait = 5 * time.Second
+
+	start := time.Now()
+	if _, err := c.Directions(context.Background(), DirectionsRequest{Coordinates: tokyoOsaka}); err != nil {
+		t.Fatalf("Directions: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want at least the 1s Retry-After", elapsed)
+	}
+}
+
+func TestRetryWaitIsCapped(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, failing(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"3600"}}, directionsBody, &calls))
+	c.MaxRetryWait = 20 * time.Millisecond
+
+	start := time.Now()
+	if _, err := c.Directions(context.Background(), DirectionsRequest{Coordinates: tokyoOsaka}); err != nil {
+		t.Fatalf("Directions: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("retried after %v, want about MaxRetryWait", elapsed)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"0", 0},
+		{"-3", 0},
+		{"soon", 0},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCancelDuringBackoff(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, failing(100, http.StatusServiceUnavailable, nil, directionsBody, &calls))
+	c.RetryBackoff = time.Hour
+	c.MaxRetryWait = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := c.Directions(ctx, DirectionsRequest{Coordinates: tokyoOsaka})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("returned after %v, want soon after cancel", elapsed)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestErrorsOmitToken(t *testing.T) {
+	t.Run("status", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		})
+		c.MaxRetries = 0
+		_, err := c.Geocode(context.Background(), GeocodingRequest{Query: "Kyoto"})
+		if err == nil || strings.Contains(err.Error(), testToken) {
+			t.Errorf("err = %v, want an error without the token", err)
+		}
+	})
+
+	t.Run("decode", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "not json")
+		})
+		_, err := c.Geocode(context.Background(), GeocodingRequest{Query: "Kyoto"})
+		if err == nil || strings.Contains(err.Error(), testToken) {
+			t.Errorf("err = %v, want an error without the token", err)
+		}
+	})
+
+	t.Run("transport", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		srv.Close()
+		c := New(testToken)
+		c.BaseURL = srv.URL
+		c.MaxRetries = 0
+		_, err := c.Geocode(context.Background(), GeocodingRequest{Query: "Kyoto"})
+		if err == nil || strings.Contains(err.Error(), testToken) {
+			t.Errorf("err = %v, want an error without the token", err)
+		}
+	})
+}
+
+func TestNoToken(t *testing.T) {
+	c := New("")
+	if _, err := c.Geocode(context.Background(), GeocodingRequest{Query: "Kyoto"}); !errors.Is(err, ErrNoToken) {
+		t.Errorf("err = %v, want ErrNoToken", err)
+	}
+}
+
+func TestDirectionsRequest(t *testing.T) {
+	var got *http.Request
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		fmt.Fprint(w, directionsBody)
+	})
+	c.BaseURL += "/proxy"
+
+	steps := true
+	resp, err := c.Directions(context.Background(), DirectionsRequest{
+		Profile:     ProfileWalking,
+		Coordinates: tokyoOsaka,
+		Steps:       &steps,
+		Annotations: []string{"duration", "distance"},
+		Language:    "ja",
+	})
+	if err != nil {
+		t.Fatalf("Directions: %v", err)
+	}
+
+	if want := "/proxy/directions/v5/mapbox/walking/139.76,35.68;135.5,34.69"; got.URL.Path != want {
+		t.Errorf("path = %q, want %q", got.URL.Path, want)
+	}
+	q := got.URL.Query()
+	for key, want := range map[string]string{
+		"access_token": testToken,
+		"geometries":   "geojson",
+		"overview":     "full",
+		"steps":        "true",
+		"annotations":  "duration,distance",
+		"language":     "ja",
+	} {
+		if q.Get(key) != want {
+			t.Errorf("query %s = %q, want %q", key, q.Get(key), want)
+		}
+	}
+	if q.Has("alternatives") {
+		t.Errorf("alternatives sent though unset")
+	}
+
+	if len(resp.Routes) != 1 {
+		t.Fatalf("routes = %d, want 1", len(resp.Routes))
+	}
+	route := resp.Routes[0]
+	if route.Geometry.Type != "LineString" || len(route.Geometry.Coordinates) != 2 || route.Geometry.Coordinates[1][0] != 135.5 {
+		t.Errorf("geometry = %+v", route.Geometry)
+	}
+	if route.Distance != 503123.4 || route.Duration != 21600.5 || route.WeightName != "auto" {
+		t.Errorf("route = %+v", route)
+	}
+	if string(route.Legs) != `[{"summary": "Tomei"}]` {
+		t.Errorf("legs = %s, want them passed through", route.Legs)
+	}
+	if resp.UUID != "abc" || len(resp.Waypoints) == 0 {
+		t.Errorf("response = %+v", resp)
+	}
+	if string(resp.Raw) != directionsBody {
+		t.Errorf("raw = %s, want the body as received", resp.Raw)
+	}
+}
+
+func TestDirectionsNeedTwoCoordinates(t *testing.T) {
+	c := New(testToken)
+	if _, err := c.Directions(context.Background(), DirectionsRequest{Coordinates: tokyoOsaka[:1]}); err == nil {
+		t.Error("want an error for a single coordinate")
+	}
+}
+
+func TestGeocode(t *testing.T) {
+	var got *http.Request
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		fmt.Fprint(w, `{
+			"type": "FeatureCollection",
+			"query": ["kyoto"],
+			"features": [{
+				"id": "place.123",
+				"type": "Feature",
+				"place_type": ["place"],
+				"relevance": 1,
+				"text": "Kyoto",
+				"place_name": "Kyoto, Japan",
+				"center": [135.7681, 35.0116],
+				"geometry": {"type": "Point", "coordinates": [135.7681, 35.0116]},
+				"context": [{"id": "country.1", "text": "Japan"}]
+			}],
+			"attribution": "Mapbox"
+		}`)
+	})
+
+	resp, err := c.Geocode(context.Background(), GeocodingRequest{Query: "a/b?c", Country: []string{"jp"}, Limit: 3})
+	if err != nil {
+		t.Fatalf("Geocode: %v", err)
+	}
+
+	if want := "/geocoding/v5/mapbox.places/a%2Fb%3Fc.json"; got.URL.EscapedPath() != want {
+		t.Errorf("path = %q, want %q", got.URL.EscapedPath(), want)
+	}
+	if q := got.URL.Query(); q.Get("country") != "jp" || q.Get("limit") != "3" {
+		t.Errorf("query = %v", q)
+	}
+
+	if len(resp.Features) != 1 {
+		t.Fatalf("features = %d, want 1", len(resp.Features))
+	}
+	f := resp.Features[0]
+	if f.PlaceName != "Kyoto, Japan" || f.PlaceType[0] != "place" || f.Center[0] != 135.7681 || f.Center[1] != 35.0116 {
+		t.Errorf("feature = %+v", f)
+	}
+	if len(f.Geometry) == 0 || len(f.Context) == 0 {
+		t.Errorf("geometry and context should be passed through: %+v", f)
+	}
+	if resp.Attribution != "Mapbox" {
+		t.Errorf("attribution = %q", resp.Attribution)
+	}
+}
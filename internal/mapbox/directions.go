@@ -0,0 +1,116 @@
+package mapbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Directions routing profiles
+const (
+	ProfileDriving        = "driving"
+	ProfileDrivingTraffic = "driving-traffic"
+	ProfileWalking        = "walking"
+	ProfileCycling        = "cycling"
+)
+
+// Profiles lists every Directions routing profile
+var Profiles = []string{ProfileDriving, ProfileDrivingTraffic, ProfileWalking, ProfileCycling}
+
+// LngLat is a position in degrees
+type LngLat struct {
+	Lng float64
+	Lat float64
+}
+
+func (p LngLat) String() string {
+	return strconv.FormatFloat(p.Lng, 'f', -1, 64) + "," + strconv.FormatFloat(p.Lat, 'f', -1, 64)
+}
+
+// DirectionsRequest describes a Directions API call. Geometries are always
+// GeoJSON and the overview is always full.
+type DirectionsRequest struct {
+	Profile      string // one of Profiles; empty means driving
+	Coordinates  []LngLat
+	Alternatives *bool
+	Steps        *bool
+	Annotations  []string
+	Exclude      []string
+	Language     string
+}
+
+// LineString is a GeoJSON LineString geometry
+type LineString struct {
+	Type        string      `json:"type"`
+	Coordinates [][]float64 `json:"coordinates"` // [lng, lat] pairs
+}
+
+// Route is one route in a Directions response. Legs (with steps and
+// annotations, when requested) are passed through undecoded.
+type Route struct {
+	Geometry   LineString      `json:"geometry"`
+	Distance   float64         `json:"distance"` // meters
+	Duration   float64         `json:"duration"` // seconds
+	Weight     float64         `json:"weight"`
+	WeightName string          `json:"weight_name"`
+	Legs       json.RawMessage `json:"legs,omitempty"`
+}
+
+// DirectionsResponse is the body of a Directions API response
+type DirectionsResponse struct {
+	Code      string          `json:"code"`
+	Message   string          `json:"message,omitempty"`
+	Routes    []Route         `json:"routes"`
+	Waypoints json.RawMessage `json:"waypoints,omitempty"`
+	UUID      string          `json:"uuid,omitempty"`
+
+	// Raw is the response body as Mapbox sent it, including fields not modeled above
+	Raw json.RawMessage `json:"-"`
+}
+
+// Directions requests routes through the given coordinates
+func (c *Client) Directions(ctx context.Context, req DirectionsRequest) (*DirectionsResponse, error) {
+	if len(req.Coordinates) < 2 {
+		return nil, fmt.Errorf("mapbox: directions need at least 2 coordinates, got %d", len(req.Coordinates))
+	}
+	profile := req.Profile
+	if profile == "" {
+		profile = ProfileDriving
+	}
+
+	coords := make([]string, len(req.Coordinates))
+	for i, p := range req.Coordinates {
+		coords[i] = p.String()
+	}
+	path := "/directions/v5/mapbox/" + url.PathEscape(profile) + "/" + strings.Join(coords, ";")
+
+	query := url.Values{}
+	query.Set("geometries", "geojson")
+	query.Set("overview", "full")
+	if req.Alternatives != nil {
+		query.Set("alternatives", strconv.FormatBool(*req.Alternatives))
+	}
+	if req.Steps != nil {
+		query.Set("steps", strconv.FormatBool(*req.Steps))
+	}
+	if len(req.Annotations) > 0 {
+		query.Set("annotations", strings.Join(req.Annotations, ","))
+	}
+	if len(req.Exclude) > 0 {
+		query.Set("exclude", strings.Join(req.Exclude, ","))
+	}
+	if req.Language != "" {
+		query.Set("language", req.Language)
+	}
+
+	var resp DirectionsResponse
+	body, err := c.get(ctx, path, query, &resp)
+	if err != nil {
+		return nil, err
+	}
+	resp.Raw = body
+	return &resp, nil
+}
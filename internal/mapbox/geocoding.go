@@ -0,0 +1,65 @@
+package mapbox
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// GeocodingRequest describes a forward geocoding search
+type GeocodingRequest struct {
+	Query   string
+	Country []string // ISO 3166 alpha-2 codes to restrict results to
+	Limit   int      // 0 uses the API default
+}
+
+// GeocodingFeature is one place in a geocoding response. Properties and the
+// surrounding-area context are passed through undecoded.
+type GeocodingFeature struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	PlaceType  []string        `json:"place_type"`
+	Relevance  float64         `json:"relevance"`
+	Text       string          `json:"text"`
+	PlaceName  string          `json:"place_name"`
+	Center     []float64       `json:"center"` // [lng, lat]
+	BBox       []float64       `json:"bbox,omitempty"`
+	Geometry   json.RawMessage `json:"geometry"`
+	Properties json.RawMessage `json:"properties,omitempty"`
+	Context    json.RawMessage `json:"context,omitempty"`
+}
+
+// GeocodingResponse is the body of a geocoding response (a GeoJSON FeatureCollection)
+type GeocodingResponse struct {
+	Type        string             `json:"type"`
+	Query       json.RawMessage    `json:"query"`
+	Features    []GeocodingFeature `json:"features"`
+	Attribution string             `json:"attribution,omitempty"`
+
+	// Raw is the response body as Mapbox sent it, including fields not modeled above
+	Raw json.RawMessage `json:"-"`
+}
+
+// Geocode searches for places matching a free-text query
+func (c *Client) Geocode(ctx context.Context, req GeocodingRequest) (*GeocodingResponse, error) {
+	// The search text is a path segment, so "/" and "?" in it must stay escaped
+	path := "/geocoding/v5/mapbox.places/" + url.PathEscape(req.Query) + ".json"
+
+	query := url.Values{}
+	if len(req.Country) > 0 {
+		query.Set("country", strings.Join(req.Country, ","))
+	}
+	if req.Limit > 0 {
+		query.Set("limit", strconv.Itoa(req.Limit))
+	}
+
+	var resp GeocodingResponse
+	body, err := c.get(ctx, path, query, &resp)
+	if err != nil {
+		return nil, err
+	}
+	resp.Raw = body
+	return &resp, nil
+}
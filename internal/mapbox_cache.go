@@ -0,0 +1,311 @@
+package internal
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mapboxEntry is one cached Mapbox response in the LRU list
+type mapboxEntry struct {
+	key     string
+	body    []byte
+	expires time.Time
+}
+
+// mapboxCall is an in-flight Mapbox request that concurrent misses wait on
+type mapboxCall struct {
+	done chan struct{}
+	body []byte
+	err  error
+}
+
+// mapboxTempPrefix starts the names of the cache's temp files. Together with
+// the hashed response names from diskPath it is how sweeps recognize the
+// cache's own files, leaving anything else in the directory alone.
+const mapboxTempPrefix = ".mapbox-cache-"
+
+// errMapboxFetchPanicked is returned to callers waiting on a fetch that panicked
+var errMapboxFetchPanicked = errors.New("mapbox fetch panicked")
+
+// mapboxDiskEntry is the on-disk form of a cached response
+type mapboxDiskEntry struct {
+	Key     string          `json:"key"`
+	Expires time.Time       `json:"expires"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// MapboxCache holds Mapbox proxy responses keyed by their normalized request,
+// in an LRU bounded by entry count and, when dir is set, in one file per
+// response so the cache survives restarts. The directory is bounded by file
+// count: expired files are swept when the cache is created, and the oldest
+// files are deleted whenever it grows past maxFiles. Entries always expire
+// after ttl: Mapbox terms only allow temporary caching of API results.
+// Concurrent misses for the same key share one upstream request.
+type MapboxCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	entries    map[string]*list.Element
+	order      *list.List // front is most recently used
+	inflight   map[string]*mapboxCall
+
+	diskMu    sync.Mutex // guards diskFiles and serializes sweeps
+	dir       string
+	maxFiles  int
+	diskFiles int // response files in dir
+}
+
+// NewMapboxCache creates a cache of at most maxEntries responses in memory,
+// each expiring ttl after it was fetched. A maxEntries of 0 disables caching
+// (concurrent misses are still coalesced). dir is the on-disk store, holding
+// at most maxFiles responses; empty keeps responses in memory only.
+func NewMapboxCache(maxEntries int, ttl time.Duration, dir string, maxFiles int) *MapboxCache {
+	c := &MapboxCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		inflight:   make(map[string]*mapboxCall),
+		dir:        dir,
+		maxFiles:   maxFiles,
+	}
+	if dir != "" {
+		c.diskMu.Lock()
+		c.sweepDisk()
+		c.diskMu.Unlock()
+	}
+	return c
+}
+
+// Get returns the cached response body for key, calling fetch on a miss.
+// hit reports whether the body came from the cache (memory or disk).
+func (c *MapboxCache) Get(key string, fetch func() ([]byte, error)) (body []byte, hit bool, err error) {
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*mapboxEntry)
+		if time.Now().Before(entry.expires) {
+			c.order.MoveToFront(elem)
+			c.mu.Unlock()
+			return entry.body, true, nil
+		}
+		c.remove(elem)
+	}
+
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.body, false, call.err
+	}
+
+	// If fetch panics, the error stays set for any waiting callers and the
+	// deferred cleanup still releases them before the panic propagates
+	call := &mapboxCall{done: make(chan struct{}), err: errMapboxFetchPanicked}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	var expires time.Time
+	defer func() {
+		c.mu.Lock()
+		delete(c.inflight, key)
+		if call.err == nil {
+			c.add(key, call.body, expires)
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+
+	if c.maxEntries > 0 {
+		if body, diskExpires, ok := c.readDisk(key); ok {
+			call.body, call.err, expires = body, nil, diskExpires
+			return body, true, nil
+		}
+	}
+
+	call.body, call.err = fetch()
+	if call.err == nil {
+		expires = time.Now().Add(c.ttl)
+		c.writeDisk(key, call.body, expires)
+	}
+	return call.body, false, call.err
+}
+
+// add stores a response and evicts least recently used responses until the
+// cache fits. Caller must hold c.mu.
+func (c *MapboxCache) add(key string, body []byte, expires time.Time) {
+	if c.maxEntries <= 0 {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+
+	entry := &mapboxEntry{key: key, body: body, expires: expires}
+	c.entries[key] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops an entry from memory. Caller must hold c.mu.
+func (c *MapboxCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*mapboxEntry)
+	delete(c.entries, entry.key)
+}
+
+// diskPath returns the file holding key's response. Keys are hashed since they
+// contain characters that are not safe in file names.
+func (c *MapboxCache) diskPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// isMapboxResponseFile reports whether name is one diskPath produces: a
+// sha256 in hex followed by .json
+func isMapboxResponseFile(name string) bool {
+	hash, ok := strings.CutSuffix(name, ".json")
+	if !ok || len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil && hash == strings.ToLower(hash)
+}
+
+// readDisk loads an unexpired response from the on-disk store, deleting it
+// if it has expired
+func (c *MapboxCache) readDisk(key string) ([]byte, time.Time, bool) {
+	if c.dir == "" {
+		return nil, time.Time{}, false
+	}
+	path := c.diskPath(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+
+	var entry mapboxDiskEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key || !time.Now().Before(entry.Expires) {
+		c.removeDisk(path)
+		return nil, time.Time{}, false
+	}
+	return entry.Body, entry.Expires, true
+}
+
+// removeDisk deletes one response file from the on-disk store
+func (c *MapboxCache) removeDisk(path string) {
+	c.diskMu.Lock()
+	defer c.diskMu.Unlock()
+	if os.Remove(path) == nil {
+		c.diskFiles--
+	}
+}
+
+// writeDisk saves a response to the on-disk store. Failures are logged and
+// otherwise ignored, leaving the response cached in memory only.
+func (c *MapboxCache) writeDisk(key string, body []byte, expires time.Time) {
+	if c.dir == "" || c.maxEntries <= 0 {
+		return
+	}
+	data, err := json.Marshal(mapboxDiskEntry{Key: key, Expires: expires, Body: body})
+	if err != nil {
+		log.Printf("mapbox cache: encoding %s: %v", key, err)
+		return
+	}
+
+	// Write to a temp file and rename so readers never see a partial file
+	path := c.diskPath(key)
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		log.Printf("mapbox cache: %v", err)
+		return
+	}
+	tmp, err := os.CreateTemp(c.dir, mapboxTempPrefix+"*")
+	if err != nil {
+		log.Printf("mapbox cache: %v", err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	c.diskMu.Lock()
+	defer c.diskMu.Unlock()
+	_, statErr := os.Stat(path)
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("mapbox cache: writing %s: %v", path, err)
+		return
+	}
+	if errors.Is(statErr, fs.ErrNotExist) {
+		c.diskFiles++
+	}
+	if c.diskFiles > c.maxFiles {
+		c.sweepDisk()
+	}
+}
+
+// sweepDisk deletes expired response files (and temp files left by interrupted
+// writes), ignoring files the cache did not write, and then, if more than maxFiles remain, the oldest until the store is
+// down to 90% of maxFiles, so the writes right after a trim don't each trigger
+// another sweep. Files are aged by modification time, which is when the
+// response was fetched. Caller must hold c.diskMu.
+func (c *MapboxCache) sweepDisk() {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("mapbox cache: %v", err)
+		}
+		c.diskFiles = 0
+		return
+	}
+
+	type diskFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []diskFile
+	now := time.Now()
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		isResponse := isMapboxResponseFile(name)
+		if dirEntry.IsDir() || !(isResponse || strings.HasPrefix(name, mapboxTempPrefix)) {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(c.dir, name)
+		if !now.Before(info.ModTime().Add(c.ttl)) {
+			os.Remove(path)
+			continue
+		}
+		if isResponse {
+			files = append(files, diskFile{path: path, modTime: info.ModTime()})
+		}
+	}
+
+	if len(files) > c.maxFiles {
+		slices.SortFunc(files, func(a, b diskFile) int { return a.modTime.Compare(b.modTime) })
+		excess := len(files) - (c.maxFiles - c.maxFiles/10)
+		for _, f := range files[:excess] {
+			os.Remove(f.path)
+		}
+		files = files[excess:]
+	}
+	c.diskFiles = len(files)
+}
@@ -0,0 +1,265 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingFetch returns body and counts its calls in calls
+func countingFetch(body string, calls *atomic.Int32) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		calls.Add(1)
+		return []byte(body), nil
+	}
+}
+
+// cacheFiles lists the response files in dir
+func cacheFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestMapboxCacheHit(t *testing.T) {
+	c := NewMapboxCache(10, time.Hour, "", 0)
+	var calls atomic.Int32
+
+	body, hit, err := c.Get("k", countingFetch(`{"a":1}`, &calls))
+	if err != nil || hit || string(body) != `{"a":1}` {
+		t.Fatalf("first Get = %s, %v, %v; want a miss", body, hit, err)
+	}
+	body, hit, err = c.Get("k", countingFetch(`{"a":2}`, &calls))
+	if err != nil || !hit || string(body) != `{"a":1}` {
+		t.Fatalf("second Get = %s, %v, %v; want the cached body", body, hit, err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("fetches = %d, want 1", got)
+	}
+}
+
+func TestMapboxCacheExpiry(t *testing.T) {
+	c := NewMapboxCache(10, 20*time.Millisecond, "", 0)
+	var calls atomic.Int32
+
+	c.Get("k", countingFetch(`1`, &calls))
+	time.Sleep(30 * time.Millisecond)
+	body, hit, _ := c.Get("k", countingFetch(`2`, &calls))
+	if hit || string(body) != "2" {
+		t.Errorf("Get after ttl = %s, hit %v; want a fresh fetch", body, hit)
+	}
+}
+
+func TestMapboxCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewMapboxCache(2, time.Hour, "", 0)
+	var calls atomic.Int32
+
+	c.Get("a", countingFetch(`a`, &calls))
+	c.Get("b", countingFetch(`b`, &calls))
+	c.Get("a", countingFetch(`a`, &calls))
+	c.Get("c", countingFetch(`c`, &calls))
+
+	if _, hit, _ := c.Get("a", countingFetch(`a`, &calls)); !hit {
+		t.Error("recently used a was evicted")
+	}
+	if _, hit, _ := c.Get("b", countingFetch(`b`, &calls)); hit {
+		t.Error("least recently used b was kept")
+	}
+}
+
+func TestMapboxCacheDoesNotStoreErrors(t *testing.T) {
+	c := NewMapboxCache(10, time.Hour, "", 0)
+	fail := errors.New("upstream down")
+
+	if _, _, err := c.Get("k", func() ([]byte, error) { return nil, fail }); !errors.Is(err, fail) {
+		t.Fatalf("err = %v, want %v", err, fail)
+	}
+	var calls atomic.Int32
+	if _, hit, err := c.Get("k", countingFetch(`ok`, &calls)); hit || err != nil {
+		t.Errorf("Get after error = hit %v, %v; want a fresh fetch", hit, err)
+	}
+}
+
+func TestMapboxCacheCoalescesMisses(t *testing.T) {
+	c := NewMapboxCache(10, time.Hour, "", 0)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fetch := func() ([]byte, error) {
+		calls.Add(1)
+		<-release
+		return []byte(`shared`), nil
+	}
+
+	const callers = 8
+	var wg sync.WaitGroup
+	bodies := make([]string, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body, _, _ := c.Get("k", fetch)
+			bodies[i] = string(body)
+		}()
+	}
+	// Give every caller time to find the in-flight fetch before it finishes
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("fetches = %d, want 1", got)
+	}
+	for i, body := range bodies {
+		if body != "shared" {
+			t.Errorf("caller %d got %q", i, body)
+		}
+	}
+}
+
+func TestMapboxCachePanicReleasesWaiters(t *testing.T) {
+	c := NewMapboxCache(10, time.Hour, "", 0)
+	started := make(chan struct{})
+
+	go func() {
+		defer func() { recover() }()
+		c.Get("k", func() ([]byte, error) {
+			close(started)
+			time.Sleep(20 * time.Millisecond)
+			panic("boom")
+		})
+	}()
+	<-started
+
+	done := make(chan error)
+	go func() {
+		_, _, err := c.Get("k", func() ([]byte, error) { return []byte(`late`), nil })
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil && !errors.Is(err, errMapboxFetchPanicked) {
+			t.Errorf("err = %v, want nil or errMapboxFetchPanicked", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("waiter was not released after the fetch panicked")
+	}
+}
+
+func TestMapboxCacheDiskRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	var calls atomic.Int32
+
+	first := NewMapboxCache(10, time.Hour, dir, 100)
+	first.Get("directions?a", countingFetch(`{"routes":[]}`, &calls))
+	if files := cacheFiles(t, dir); len(files) != 1 {
+		t.Fatalf("files = %v, want 1", files)
+	}
+
+	// A new cache (as after a restart) serves the response from disk
+	second := NewMapboxCache(10, time.Hour, dir, 100)
+	body, hit, err := second.Get("directions?a", countingFetch(`{"routes":["new"]}`, &calls))
+	if err != nil || !hit || string(body) != `{"routes":[]}` {
+		t.Errorf("Get = %s, %v, %v; want the body from disk", body, hit, err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("fetches = %d, want 1", got)
+	}
+}
+
+func TestMapboxCacheDiskExpiry(t *testing.T) {
+	dir := t.TempDir()
+	var calls atomic.Int32
+
+	NewMapboxCache(10, 20*time.Millisecond, dir, 100).Get("k", countingFetch(`old`, &calls))
+	time.Sleep(30 * time.Millisecond)
+
+	c := NewMapboxCache(10, time.Hour, dir, 100)
+	if files := cacheFiles(t, dir); len(files) != 0 {
+		t.Errorf("files after startup sweep = %v, want none", files)
+	}
+	if body, hit, _ := c.Get("k", countingFetch(`new`, &calls)); hit || string(body) != "new" {
+		t.Errorf("Get = %s, hit %v; want a fresh fetch", body, hit)
+	}
+}
+
+func TestMapboxCacheSweepsExpiredFilesOnStartup(t *testing.T) {
+	dir := t.TempDir()
+	NewMapboxCache(10, time.Hour, dir, 100).Get("k", func() ([]byte, error) { return []byte(`1`), nil })
+	stale := filepath.Join(dir, mapboxTempPrefix+"123")
+	if err := os.WriteFile(stale, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	for _, path := range append(cacheFiles(t, dir), stale) {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	NewMapboxCache(10, time.Hour, dir, 100)
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("%d files left after startup sweep, want none", len(entries))
+	}
+}
+
+func TestMapboxCacheSweepKeepsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	c := NewMapboxCache(100, time.Hour, dir, 2)
+	old := time.Now().Add(-2 * time.Hour)
+	others := []string{"foo.json", "cached_routes.json", strings.Repeat("A", 64) + ".json", ".tmp-123"}
+	for _, name := range others {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(`{}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Going past the cap of 2 triggers a trim, as does the next startup
+	for _, key := range []string{"a", "b", "c"} {
+		c.Get(key, func() ([]byte, error) { return []byte(`1`), nil })
+	}
+	NewMapboxCache(100, time.Hour, dir, 2)
+
+	for _, name := range others {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was removed by the sweep: %v", name, err)
+		}
+	}
+}
+
+func TestMapboxCacheDiskCap(t *testing.T) {
+	dir := t.TempDir()
+	c := NewMapboxCache(100, time.Hour, dir, 10)
+
+	start := time.Now().Add(-30 * time.Minute)
+	for i := range 11 {
+		key := string(rune('a' + i))
+		c.Get(key, func() ([]byte, error) { return []byte(`1`), nil })
+		// Space the modification times out so the age order is unambiguous
+		at := start.Add(time.Duration(i) * time.Second)
+		os.Chtimes(c.diskPath(key), at, at)
+	}
+
+	files := cacheFiles(t, dir)
+	if len(files) != 9 {
+		t.Errorf("files = %d, want 9 after trimming past the cap of 10", len(files))
+	}
+	if _, err := os.Stat(c.diskPath("a")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("oldest file still present (err %v)", err)
+	}
+	if _, err := os.Stat(c.diskPath("k")); err != nil {
+		t.Errorf("newest file missing: %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hunterjsb/tokygo/internal/mapbox"
+)
+
+// mapboxMaxCoordinates is the Directions API limit on waypoints per request
+const mapboxMaxCoordinates = 25
+
+// mapboxAnnotations are the allowed values of the annotations option
+var mapboxAnnotations = []string{"distance", "duration", "speed", "congestion", "congestion_numeric", "maxspeed", "closure"}
+
+// mapboxExcludes are the allowed values of the exclude option
+var mapboxExcludes = []string{"motorway", "toll", "ferry", "unpaved", "cash_only_tolls", "tunnel"}
+
+// languageTagPattern matches IETF language tags such as "ja" or "en-US"
+var languageTagPattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// mapboxConfigured reports whether MAPBOX_TOKEN is set, writing a 503 if not
+func (s *Server) mapboxConfigured(w http.ResponseWriter) bool {
+	if s.mapbox.Token == "" {
+		writeError(w, http.StatusServiceUnavailable, APIError{
+			Code:    ErrCodeNotConfigured,
+			Message: "Mapbox token not configured",
+		})
+		return false
+	}
+	return true
+}
+
+// handleMapboxDirections proxies requests to Mapbox Directions API.
+// Query params:
+// - coordinates: "lng,lat;lng,lat[;...]" with 2 to 25 positions (required)
+// - profile: driving (default), driving-traffic, walking or cycling
+// - alternatives, steps: true or false
+// - annotations: comma-separated subset of mapboxAnnotations
+// - exclude: comma-separated subset of mapboxExcludes
+// - language: IETF language tag for step instructions
+//
+// Responses always use geometries=geojson and overview=full.
+func (s *Server) handleMapboxDirections(w http.ResponseWriter, r *http.Request) {
+	if !s.mapboxConfigured(w) {
+		return
+	}
+
+	query := r.URL.Query()
+
+	coordinates, ok := parseMapboxCoordinates(w, query.Get("coordinates"))
+	if !ok {
+		return
+	}
+
+	profile := query.Get("profile")
+	if profile == "" {
+		profile = mapbox.ProfileDriving
+	}
+	if !slices.Contains(mapbox.Profiles, profile) {
+		writeError(w, http.StatusBadRequest, APIError{
+			Code:    ErrCodeInvalidValue,
+			Field:   "profile",
+			Message: fmt.Sprintf("unknown profile %q", profile),
+			Details: mapbox.Profiles,
+		})
+		return
+	}
+
+	req := mapbox.DirectionsRequest{Profile: profile, Coordinates: coordinates}
+
+	boolOptions := []struct {
+		name   string
+		target **bool
+	}{
+		{"alternatives", &req.Alternatives},
+		{"steps", &req.Steps},
+	}
+	for _, option := range boolOptions {
+		if query.Get(option.name) == "" {
+			continue
+		}
+		v, ok := queryBool(w, r, option.name)
+		if !ok {
+			return
+		}
+		*option.target = &v
+	}
+	listOptions := []struct {
+		name    string
+		allowed []string
+		target  *[]string
+	}{
+		{"annotations", mapboxAnnotations, &req.Annotations},
+		{"exclude", mapboxExcludes, &req.Exclude},
+	}
+	for _, option := range listOptions {
+		name, allowed := option.name, option.allowed
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		values := strings.Split(raw, ",")
+		for _, value := range values {
+			if !slices.Contains(allowed, value) {
+				writeError(w, http.StatusBadRequest, APIError{
+					Code:    ErrCodeInvalidValue,
+					Field:   name,
+					Message: fmt.Sprintf("unknown %s value %q", name, value),
+					Details: allowed,
+				})
+				return
+			}
+		}
+		*option.target = values
+	}
+	if language := query.Get("language"); language != "" {
+		if !languageTagPattern.MatchString(language) {
+			writeParamError(w, ErrCodeInvalidValue, "language", fmt.Sprintf("language must be a language tag such as ja or en-US, got %q", language))
+			return
+		}
+		req.Language = language
+	}
+
+	s.serveMapbox(w, r, directionsCacheKey(req), func(ctx context.Context) ([]byte, error) {
+		resp, err := s.mapbox.Directions(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Raw, nil
+	})
+}
+
+// directionsCacheKey identifies a Directions request by its normalized
+// options. Coordinates are already re-formatted from parsed numbers, and the
+// order of annotations and excludes does not change the response.
+func directionsCacheKey(req mapbox.DirectionsRequest) string {
+	coords := make([]string, len(req.Coordinates))
+	for i, p := range req.Coordinates {
+		coords[i] = p.String()
+	}
+
+	key := url.Values{}
+	key.Set("coordinates", strings.Join(coords, ";"))
+	if req.Alternatives != nil {
+		key.Set("alternatives", strconv.FormatBool(*req.Alternatives))
+	}
+	if req.Steps != nil {
+		key.Set("steps", strconv.FormatBool(*req.Steps))
+	}
+	if len(req.Annotations) > 0 {
+		key.Set("annotations", strings.Join(slices.Sorted(slices.Values(req.Annotations)), ","))
+	}
+	if len(req.Exclude) > 0 {
+		key.Set("exclude", strings.Join(slices.Sorted(slices.Values(req.Exclude)), ","))
+	}
+	if req.Language != "" {
+		key.Set("language", strings.ToLower(req.Language))
+	}
+	return "directions/" + req.Profile + "?" + key.Encode()
+}
+
+// parseMapboxCoordinates validates a "lng,lat;lng,lat" list. Only the parsed
+// numbers reach the upstream path.
+func parseMapboxCoordinates(w http.ResponseWriter, raw string) ([]mapbox.LngLat, bool) {
+	if raw == "" {
+		writeParamError(w, ErrCodeRequired, "coordinates", "coordinates parameter required")
+		return nil, false
+	}
+
+	pairs := strings.Split(raw, ";")
+	if len(pairs) < 2 || len(pairs) > mapboxMaxCoordinates {
+		writeParamError(w, ErrCodeOutOfRange, "coordinates",
+			fmt.Sprintf("coordinates must list between 2 and %d positions, got %d", mapboxMaxCoordinates, len(pairs)))
+		return nil, false
+	}
+
+	coordinates := make([]mapbox.LngLat, len(pairs))
+	for i, pair := range pairs {
+		lngStr, latStr, found := strings.Cut(pair, ",")
+		lng, errLng := strconv.ParseFloat(lngStr, 64)
+		lat, errLat := strconv.ParseFloat(latStr, 64)
+		if !found || errLng != nil || errLat != nil || lng < -180 || lng > 180 || lat < -90 || lat > 90 {
+			writeParamError(w, ErrCodeInvalidValue, "coordinates",
+				fmt.Sprintf("position %d must be lng,lat in degrees, got %q", i+1, pair))
+			return nil, false
+		}
+		coordinates[i] = mapbox.LngLat{Lng: lng, Lat: lat}
+	}
+	return coordinates, true
+}
+
+// handleMapboxGeocoding proxies requests to Mapbox Geocoding API
+func (s *Server) handleMapboxGeocoding(w http.ResponseWriter, r *http.Request) {
+	if !s.mapboxConfigured(w) {
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeParamError(w, ErrCodeRequired, "q", "q parameter required")
+		return
+	}
+
+	// Searches differing only in case or spacing share a cache entry, since
+	// Mapbox matches case-insensitively; the search itself goes upstream as typed
+	req := mapbox.GeocodingRequest{Query: query, Country: []string{"JP"}}
+	keyQuery := strings.ToLower(strings.Join(strings.Fields(query), " "))
+	key := "geocoding?" + url.Values{"q": {keyQuery}, "country": req.Country}.Encode()
+	s.serveMapbox(w, r, key, func(ctx context.Context) ([]byte, error) {
+		resp, err := s.mapbox.Geocode(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Raw, nil
+	})
+}
+
+// serveMapbox writes the response for a Mapbox request from the proxy cache,
+// calling fetch on a miss. fetch returns the upstream body unchanged, so fields
+// the typed responses do not model still reach the client. Only successful
+// responses are cached. The fetch
+// outlives the client that triggered it, since other requests may be waiting
+// on it; the Mapbox client's own timeout still bounds it.
+func (s *Server) serveMapbox(w http.ResponseWriter, r *http.Request, key string, fetch func(ctx context.Context) ([]byte, error)) {
+	body, hit, err := s.mapboxCache.Get(key, func() ([]byte, error) {
+		return fetch(context.WithoutCancel(r.Context()))
+	})
+	if err != nil {
+		writeMapboxError(w, err)
+		return
+	}
+
+	setCacheStatus(w, hit)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// writeMapboxError reports a failed Mapbox call. Mapbox's own 4xx answers
+// (such as 422 for an unroutable request) keep their status; token problems,
+// exhausted retries and transport errors are a 502, and timeouts a 504.
+func writeMapboxError(w http.ResponseWriter, err error) {
+	status := http.StatusBadGateway
+	message := err.Error()
+
+	var apiErr *mapbox.Error
+	switch {
+	case errors.As(err, &apiErr):
+		message = apiErr.Message
+		if apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 &&
+			apiErr.StatusCode != http.StatusUnauthorized &&
+			apiErr.StatusCode != http.StatusForbidden &&
+			apiErr.StatusCode != http.StatusTooManyRequests {
+			status = apiErr.StatusCode
+		}
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	}
+
+	writeError(w, status, APIError{
+		Code:    ErrCodeUpstream,
+		Message: "Mapbox API error: " + message,
+	})
+}
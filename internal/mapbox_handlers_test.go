@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+// newMapboxTestServer returns a server whose Mapbox client talks to handler
+func newMapboxTestServer(t *testing.T, handler http.HandlerFunc) *Server {
+	t.Helper()
+	upstream := httptest.NewServer(handler)
+	t.Cleanup(upstream.Close)
+
+	s := newTestServer(t, DefaultConfig())
+	s.mapbox.BaseURL = upstream.URL
+	s.mapbox.Token = "pk.test"
+	return s
+}
+
+func TestMapboxProxyPassesBodyThrough(t *testing.T) {
+	const body = `{"code":"Ok","routes":[{"geometry":{"type":"LineString","coordinates":[[139.76,35.68],[135.5,34.69]]},` +
+		`"distance":1,"duration":2,"weight":3,"weight_name":"auto","legs":[{"annotation":{"distance":[1]}}],"voiceLocale":"ja"}],` +
+		`"waypoints":[{"name":"Tokyo","location":[139.76,35.68]}],"uuid":"abc","unmodeled":{"kept":true}}`
+	var calls atomic.Int32
+	s := newMapboxTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		fmt.Fprint(w, body)
+	})
+
+	for _, cache := range []string{"MISS", "HIT"} {
+		w := httptest.NewRecorder()
+		s.handleMapboxDirections(w, httptest.NewRequest(http.MethodGet, "/api/mapbox/directions?coordinates=139.76,35.68%3B135.5,34.69", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body)
+		}
+		if got := w.Header().Get("X-Cache"); got != cache {
+			t.Errorf("X-Cache = %q, want %q", got, cache)
+		}
+		if w.Body.String() != body {
+			t.Errorf("%s body = %s, want the upstream body unchanged", cache, w.Body)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("upstream called %d times, want 1", n)
+	}
+}
+
+func TestMapboxProxyRejectsUndecodableBody(t *testing.T) {
+	s := newMapboxTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"type":"FeatureCollection","features":{}}`)
+	})
+
+	w := httptest.NewRecorder()
+	s.handleMapboxGeocoding(w, httptest.NewRequest(http.MethodGet, "/api/mapbox/geocoding?q=kyoto", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502 for a body that does not decode", w.Code)
+	}
+}
+
+func TestMapboxGeocodingKeepsQueryCase(t *testing.T) {
+	var queries []string
+	s := newMapboxTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Path)
+		fmt.Fprint(w, `{"type":"FeatureCollection","features":[]}`)
+	})
+
+	for _, q := range []string{"%20Kyoto%20Tower%20", "kyoto%20%20tower"} {
+		w := httptest.NewRecorder()
+		s.handleMapboxGeocoding(w, httptest.NewRequest(http.MethodGet, "/api/mapbox/geocoding?q="+q, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body)
+		}
+	}
+
+	// The second search differs only in case and spacing, so it is a cache hit
+	if want := []string{"/geocoding/v5/mapbox.places/Kyoto Tower.json"}; !slices.Equal(queries, want) {
+		t.Errorf("upstream paths = %q, want %q", queries, want)
+	}
+}
@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// MemoryStore is an in-memory TripStore seeded with the built-in trip data.
+// Changes are lost when the process exits; it is intended for tests and local runs.
+type MemoryStore struct {
+	mu             sync.RWMutex
+	trips          []Trip
+	cities         []City
+	locations      []TripLocation
+	routes         []Route
+	schedules      map[int64][]ScheduleItem
+	nextTripID     int64
+	nextLocationID int64
+}
+
+// NewMemoryStore creates a MemoryStore seeded with DefaultTrip and its
+// Cities, TripLocations and TripRoutes
+func NewMemoryStore() *MemoryStore {
+	locations := seedLocations()
+	return &MemoryStore{
+		trips:          []Trip{DefaultTrip},
+		cities:         seedCities(),
+		locations:      locations,
+		routes:         seedRoutes(),
+		schedules:      map[int64][]ScheduleItem{DefaultTripID: sortedSchedule(TripSchedule)},
+		nextTripID:     DefaultTripID + 1,
+		nextLocationID: int64(len(locations) + 1),
+	}
+}
+
+func (m *MemoryStore) Trips(ctx context.Context) ([]Trip, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.trips), nil
+}
+
+func (m *MemoryStore) Trip(ctx context.Context, id int64) (Trip, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, trip := range m.trips {
+		if trip.ID == id {
+			return trip, nil
+		}
+	}
+	return Trip{}, ErrNotFound
+}
+
+func (m *MemoryStore) CreateTrip(ctx context.Context, trip Trip) (Trip, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	trip.ID = m.nextTripID
+	m.nextTripID++
+	m.trips = append(m.trips, trip)
+	return trip, nil
+}
+
+func (m *MemoryStore) UpdateTrip(ctx context.Context, trip Trip) (Trip, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.trips {
+		if m.trips[i].ID == trip.ID {
+			m.trips[i] = trip
+			return trip, nil
+		}
+	}
+	return Trip{}, ErrNotFound
+}
+
+func (m *MemoryStore) DeleteTrip(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.trips, func(t Trip) bool { return t.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	m.trips = slices.Delete(m.trips, i, i+1)
+	m.cities = slices.DeleteFunc(m.cities, func(c City) bool { return c.TripID == id })
+	m.locations = slices.DeleteFunc(m.locations, func(l TripLocation) bool { return l.TripID == id })
+	m.routes = slices.DeleteFunc(m.routes, func(r Route) bool { return r.TripID == id })
+	delete(m.schedules, id)
+	return nil
+}
+
+func (m *MemoryStore) Cities(ctx context.Context, tripID int64) ([]City, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return filterByTrip(m.cities, tripID, func(c City) int64 { return c.TripID }), nil
+}
+
+func (m *MemoryStore) CreateCity(ctx context.Context, city City) (City, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cities = append(m.cities, city)
+	return city, nil
+}
+
+func (m *MemoryStore) Locations(ctx context.Context, tripID int64) ([]TripLocation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return filterByTrip(m.locations, tripID, func(l TripLocation) int64 { return l.TripID }), nil
+}
+
+func (m *MemoryStore) Location(ctx context.Context, tripID, id int64) (TripLocation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, loc := range m.locations {
+		if loc.TripID == tripID && loc.ID == id {
+			return loc, nil
+		}
+	}
+	return TripLocation{}, ErrNotFound
+}
+
+func (m *MemoryStore) CreateLocation(ctx context.Context, loc TripLocation) (TripLocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	loc.ID = m.nextLocationID
+	m.nextLocationID++
+	m.locations = append(m.locations, loc)
+	return loc, nil
+}
+
+func (m *MemoryStore) UpdateLocation(ctx context.Context, loc TripLocation) (TripLocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.locations {
+		if m.locations[i].TripID == loc.TripID && m.locations[i].ID == loc.ID {
+			m.locations[i] = loc
+			return loc, nil
+		}
+	}
+	return TripLocation{}, ErrNotFound
+}
+
+func (m *MemoryStore) DeleteLocation(ctx context.Context, tripID, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.locations {
+		if m.locations[i].TripID == tripID && m.locations[i].ID == id {
+			var refs []ScheduleItem
+			for _, item := range m.schedules[tripID] {
+				if item.LocationID == id {
+					refs = append(refs, item)
+				}
+			}
+			if len(refs) > 0 {
+				return &ReferencedError{Items: refs}
+			}
+			m.locations = slices.Delete(m.locations, i, i+1)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *MemoryStore) Routes(ctx context.Context, tripID int64) ([]Route, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return filterByTrip(m.routes, tripID, func(r Route) int64 { return r.TripID }), nil
+}
+
+func (m *MemoryStore) Schedule(ctx context.Context, tripID int64) ([]ScheduleItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]ScheduleItem{}, m.schedules[tripID]...), nil
+}
+
+func (m *MemoryStore) ReplaceSchedule(ctx context.Context, tripID int64, items []ScheduleItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.schedules[tripID] = sortedSchedule(items)
+	return nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// filterByTrip returns the items owned by tripID
+func filterByTrip[T any](items []T, tripID int64, owner func(T) int64) []T {
+	filtered := []T{}
+	for _, item := range items {
+		if owner(item) == tripID {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,152 @@
+package mvt
+
+import "math"
+
+// Coord is an unrounded position in tile coordinates
+type Coord struct {
+	X, Y float64
+}
+
+// Round converts a Coord to an integer tile Point
+func (c Coord) Round() Point {
+	return Point{X: int32(math.Round(c.X)), Y: int32(math.Round(c.Y))}
+}
+
+// Simplify reduces a line with the Douglas-Peucker algorithm, dropping points
+// that lie within tolerance of the simplified line. Endpoints are always kept.
+func Simplify(line []Coord, tolerance float64) []Coord {
+	if len(line) < 3 || tolerance <= 0 {
+		return line
+	}
+
+	keep := make([]bool, len(line))
+	keep[0], keep[len(line)-1] = true, true
+
+	// Iterative to avoid deep recursion on long routes
+	stack := [][2]int{{0, len(line) - 1}}
+	tol2 := tolerance * tolerance
+	for len(stack) > 0 {
+		span := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		first, last := span[0], span[1]
+
+		maxDist, index := 0.0, -1
+		for i := first + 1; i < last; i++ {
+			if d := segmentDistance2(line[i], line[first], line[last]); d > maxDist {
+				maxDist, index = d, i
+			}
+		}
+		if index >= 0 && maxDist > tol2 {
+			keep[index] = true
+			stack = append(stack, [2]int{first, index}, [2]int{index, last})
+		}
+	}
+
+	out := make([]Coord, 0, len(line))
+	for i, p := range line {
+		if keep[i] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// segmentDistance2 returns the squared distance from p to segment ab
+func segmentDistance2(p, a, b Coord) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	if dx != 0 || dy != 0 {
+		t := ((p.X-a.X)*dx + (p.Y-a.Y)*dy) / (dx*dx + dy*dy)
+		switch {
+		case t > 1:
+			a = b
+		case t > 0:
+			a = Coord{a.X + dx*t, a.Y + dy*t}
+		}
+	}
+	dx, dy = p.X-a.X, p.Y-a.Y
+	return dx*dx + dy*dy
+}
+
+// ClipLine clips a line to the rectangle [min, max] on both axes, returning the
+// pieces that fall inside. A line that leaves and re-enters yields several pieces.
+func ClipLine(line []Coord, min, max float64) [][]Coord {
+	var parts [][]Coord
+	var current []Coord
+
+	for i := 0; i+1 < len(line); i++ {
+		a, b, ok := clipSegment(line[i], line[i+1], min, max)
+		if !ok {
+			if len(current) > 1 {
+				parts = append(parts, current)
+			}
+			current = nil
+			continue
+		}
+
+		if len(current) == 0 {
+			current = append(current, a)
+		} else if current[len(current)-1] != a {
+			// Segment re-entered somewhere else; start a new piece
+			parts = append(parts, current)
+			current = []Coord{a}
+		}
+		current = append(current, b)
+
+		// If the segment was cut short it leaves the rectangle here
+		if b != line[i+1] {
+			parts = append(parts, current)
+			current = nil
+		}
+	}
+	if len(current) > 1 {
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+// clipSegment clips segment ab to the rectangle using Liang-Barsky
+func clipSegment(a, b Coord, min, max float64) (Coord, Coord, bool) {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t0, t1 := 0.0, 1.0
+
+	edges := [4][2]float64{
+		{-dx, a.X - min},
+		{dx, max - a.X},
+		{-dy, a.Y - min},
+		{dy, max - a.Y},
+	}
+	for _, e := range edges {
+		p, q := e[0], e[1]
+		if p == 0 {
+			if q < 0 {
+				return a, b, false
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 {
+			if t > t1 {
+				return a, b, false
+			}
+			if t > t0 {
+				t0 = t
+			}
+		} else {
+			if t < t0 {
+				return a, b, false
+			}
+			if t < t1 {
+				t1 = t
+			}
+		}
+	}
+
+	clippedA, clippedB := a, b
+	if t0 > 0 {
+		clippedA = Coord{a.X + t0*dx, a.Y + t0*dy}
+	}
+	if t1 < 1 {
+		clippedB = Coord{a.X + t1*dx, a.Y + t1*dy}
+	}
+	return clippedA, clippedB, true
+}
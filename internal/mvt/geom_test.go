@@ -0,0 +1,64 @@
+package mvt
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSimplify(t *testing.T) {
+	line := []Coord{{0, 0}, {1, 0.1}, {2, -0.1}, {3, 5}, {4, 0}, {5, 0}}
+
+	got := Simplify(line, 0.5)
+	want := []Coord{{0, 0}, {2, -0.1}, {3, 5}, {4, 0}, {5, 0}}
+	if !slices.Equal(got, want) {
+		t.Errorf("Simplify = %v, want %v", got, want)
+	}
+
+	if got := Simplify(line, 10); !slices.Equal(got, []Coord{{0, 0}, {5, 0}}) {
+		t.Errorf("Simplify with a large tolerance = %v, want only the endpoints", got)
+	}
+	if got := Simplify(line, 0); !slices.Equal(got, line) {
+		t.Errorf("Simplify with zero tolerance changed the line: %v", got)
+	}
+}
+
+func TestClipLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line []Coord
+		want [][]Coord
+	}{
+		{
+			name: "inside",
+			line: []Coord{{1, 1}, {5, 5}, {9, 1}},
+			want: [][]Coord{{{1, 1}, {5, 5}, {9, 1}}},
+		},
+		{
+			name: "outside",
+			line: []Coord{{-5, -5}, {-1, 20}},
+			want: nil,
+		},
+		{
+			name: "crossing",
+			line: []Coord{{-10, 5}, {20, 5}},
+			want: [][]Coord{{{0, 5}, {10, 5}}},
+		},
+		{
+			name: "leaves and re-enters",
+			line: []Coord{{2, 2}, {2, 20}, {8, 20}, {8, 2}},
+			want: [][]Coord{{{2, 2}, {2, 10}}, {{8, 10}, {8, 2}}},
+		},
+	}
+	for _, tt := range tests {
+		got := ClipLine(tt.line, 0, 10)
+		if !slices.EqualFunc(got, tt.want, slices.Equal) {
+			t.Errorf("%s: ClipLine = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCoordRound(t *testing.T) {
+	if p := (Coord{1.5, -2.5}).Round(); p != (Point{2, -3}) {
+		t.Errorf("Round = %v, want 2,-3", p)
+	}
+}
@@ -0,0 +1,347 @@
+// Package mvt encodes Mapbox Vector Tiles (https://github.com/mapbox/vector-tile-spec, v2.1).
+//
+// Only encoding is supported. Geometries are given in tile coordinates
+// (0..Extent, y pointing down); use TileID.Project to convert from lng/lat.
+package mvt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// DefaultExtent is the number of integer units across a tile
+const DefaultExtent = 4096
+
+// Geometry types (vector_tile.proto GeomType)
+const (
+	geomPoint      = 1
+	geomLineString = 2
+	geomPolygon    = 3
+)
+
+// Geometry commands
+const (
+	cmdMoveTo    = 1
+	cmdLineTo    = 2
+	cmdClosePath = 7
+)
+
+// Point is a position in tile coordinates
+type Point struct {
+	X, Y int32
+}
+
+// Tile is a set of named layers
+type Tile struct {
+	Layers []*Layer
+}
+
+// Layer is a named collection of features sharing a key/value dictionary
+type Layer struct {
+	Name     string
+	Extent   uint32
+	features []feature
+	keys     []string
+	keyIndex map[string]uint32
+	values   []any
+	valIndex map[any]uint32
+}
+
+type feature struct {
+	id       uint64
+	tags     []uint32
+	geomType uint32
+	geometry []uint32
+}
+
+// NewLayer creates an empty layer with the given name and extent
+func NewLayer(name string, extent uint32) *Layer {
+	return &Layer{
+		Name:     name,
+		Extent:   extent,
+		keyIndex: make(map[string]uint32),
+		valIndex: make(map[any]uint32),
+	}
+}
+
+// Len returns the number of features in the layer
+func (l *Layer) Len() int {
+	return len(l.features)
+}
+
+// AddPoint adds a point feature
+func (l *Layer) AddPoint(id uint64, p Point, props map[string]any) {
+	l.features = append(l.features, feature{
+		id:       id,
+		tags:     l.tags(props),
+		geomType: geomPoint,
+		geometry: []uint32{command(cmdMoveTo, 1), zigzag(p.X), zigzag(p.Y)},
+	})
+}
+
+// AddLineString adds a line feature. Consecutive duplicate points are dropped;
+// lines with fewer than two distinct points are skipped.
+func (l *Layer) AddLineString(id uint64, line []Point, props map[string]any) {
+	line = dedupe(line)
+	if len(line) < 2 {
+		return
+	}
+
+	var cursor Point
+	geometry := make([]uint32, 0, 2*len(line)+2)
+	geometry = appendPath(geometry, &cursor, line)
+
+	l.features = append(l.features, feature{
+		id:       id,
+		tags:     l.tags(props),
+		geomType: geomLineString,
+		geometry: geometry,
+	})
+}
+
+// AddPolygon adds a polygon feature. The first ring is the exterior and any
+// others are holes. Rings may be open or closed and in either winding order;
+// they are normalized to the spec's winding (exterior clockwise in tile space).
+// Rings with fewer than three distinct points are skipped.
+func (l *Layer) AddPolygon(id uint64, rings [][]Point, props map[string]any) {
+	var cursor Point
+	var geometry []uint32
+
+	for i, ring := range rings {
+		ring = dedupe(ring)
+		if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+			ring = ring[:len(ring)-1]
+		}
+		if len(ring) < 3 {
+			if i == 0 {
+				return
+			}
+			continue
+		}
+
+		area := signedArea(ring)
+		if area == 0 {
+			if i == 0 {
+				return
+			}
+			continue
+		}
+		// Positive area is clockwise with y pointing down: exterior rings must be
+		// positive and interior rings negative
+		if (i == 0) != (area > 0) {
+			ring = reversed(ring)
+		}
+
+		geometry = appendPath(geometry, &cursor, ring)
+		geometry = append(geometry, command(cmdClosePath, 1))
+	}
+	if len(geometry) == 0 {
+		return
+	}
+
+	l.features = append(l.features, feature{
+		id:       id,
+		tags:     l.tags(props),
+		geomType: geomPolygon,
+		geometry: geometry,
+	})
+}
+
+// appendPath encodes a MoveTo to the first point and a LineTo through the rest
+func appendPath(geometry []uint32, cursor *Point, path []Point) []uint32 {
+	geometry = append(geometry, command(cmdMoveTo, 1))
+	geometry = appendDelta(geometry, cursor, path[0])
+	geometry = append(geometry, command(cmdLineTo, uint32(len(path)-1)))
+	for _, p := range path[1:] {
+		geometry = appendDelta(geometry, cursor, p)
+	}
+	return geometry
+}
+
+func appendDelta(geometry []uint32, cursor *Point, p Point) []uint32 {
+	geometry = append(geometry, zigzag(p.X-cursor.X), zigzag(p.Y-cursor.Y))
+	*cursor = p
+	return geometry
+}
+
+// tags converts properties to key/value index pairs, growing the layer dictionaries.
+// Keys are visited in sorted order so the same input always encodes identically.
+func (l *Layer) tags(props map[string]any) []uint32 {
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	tags := make([]uint32, 0, 2*len(props))
+	for _, key := range keys {
+		value, ok := normalizeValue(props[key])
+		if !ok {
+			continue
+		}
+
+		ki, ok := l.keyIndex[key]
+		if !ok {
+			ki = uint32(len(l.keys))
+			l.keys = append(l.keys, key)
+			l.keyIndex[key] = ki
+		}
+		vi, ok := l.valIndex[value]
+		if !ok {
+			vi = uint32(len(l.values))
+			l.values = append(l.values, value)
+			l.valIndex[value] = vi
+		}
+		tags = append(tags, ki, vi)
+	}
+	return tags
+}
+
+// normalizeValue maps Go values onto the types an MVT Value can hold
+func normalizeValue(v any) (any, bool) {
+	switch v := v.(type) {
+	case string, bool, float64, int64, uint64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case uint32:
+		return uint64(v), true
+	case fmt.Stringer:
+		return v.String(), true
+	}
+	return nil, false
+}
+
+// Marshal encodes the tile as a vector_tile.Tile protobuf message
+func (t *Tile) Marshal() []byte {
+	var buf []byte
+	for _, layer := range t.Layers {
+		if layer.Len() == 0 {
+			continue
+		}
+		buf = appendBytesField(buf, 3, layer.marshal())
+	}
+	return buf
+}
+
+func (l *Layer) marshal() []byte {
+	var buf []byte
+	buf = appendVarintField(buf, 15, 2) // version
+	buf = appendBytesField(buf, 1, []byte(l.Name))
+	for _, f := range l.features {
+		buf = appendBytesField(buf, 2, f.marshal())
+	}
+	for _, key := range l.keys {
+		buf = appendBytesField(buf, 3, []byte(key))
+	}
+	for _, value := range l.values {
+		buf = appendBytesField(buf, 4, marshalValue(value))
+	}
+	buf = appendVarintField(buf, 5, uint64(l.Extent))
+	return buf
+}
+
+func (f feature) marshal() []byte {
+	var buf []byte
+	if f.id != 0 {
+		buf = appendVarintField(buf, 1, f.id)
+	}
+	if len(f.tags) > 0 {
+		buf = appendBytesField(buf, 2, packed(f.tags))
+	}
+	buf = appendVarintField(buf, 3, uint64(f.geomType))
+	buf = appendBytesField(buf, 4, packed(f.geometry))
+	return buf
+}
+
+func marshalValue(v any) []byte {
+	var buf []byte
+	switch v := v.(type) {
+	case string:
+		buf = appendBytesField(buf, 1, []byte(v))
+	case float64:
+		buf = binary.AppendUvarint(buf, 3<<3|1) // double, 64-bit wire type
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+	case int64:
+		if v < 0 {
+			buf = appendVarintField(buf, 6, uint64((v<<1)^(v>>63))) // sint64
+		} else {
+			buf = appendVarintField(buf, 4, uint64(v))
+		}
+	case uint64:
+		buf = appendVarintField(buf, 5, v)
+	case bool:
+		b := uint64(0)
+		if v {
+			b = 1
+		}
+		buf = appendVarintField(buf, 7, b)
+	}
+	return buf
+}
+
+// Protobuf wire helpers
+
+func appendVarintField(buf []byte, field int, v uint64) []byte {
+	buf = binary.AppendUvarint(buf, uint64(field)<<3) // wire type 0
+	return binary.AppendUvarint(buf, v)
+}
+
+func appendBytesField(buf []byte, field int, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(field)<<3|2) // wire type 2
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+func packed(values []uint32) []byte {
+	buf := make([]byte, 0, len(values)*2)
+	for _, v := range values {
+		buf = binary.AppendUvarint(buf, uint64(v))
+	}
+	return buf
+}
+
+// Geometry helpers
+
+func command(id, count uint32) uint32 {
+	return (id & 0x7) | (count << 3)
+}
+
+func zigzag(n int32) uint32 {
+	return uint32((n << 1) ^ (n >> 31))
+}
+
+func dedupe(points []Point) []Point {
+	out := make([]Point, 0, len(points))
+	for i, p := range points {
+		if i > 0 && p == out[len(out)-1] {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func reversed(points []Point) []Point {
+	out := make([]Point, len(points))
+	for i, p := range points {
+		out[len(points)-1-i] = p
+	}
+	return out
+}
+
+// signedArea returns twice the shoelace area; positive means clockwise in tile space (y down)
+func signedArea(ring []Point) int64 {
+	var sum int64
+	for i := range ring {
+		a, b := ring[i], ring[(i+1)%len(ring)]
+		sum += int64(a.X)*int64(b.Y) - int64(b.X)*int64(a.Y)
+	}
+	return sum
+}
@@ -0,0 +1,319 @@
+package mvt
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+)
+
+// The decoder below reads back just enough of the vector_tile.proto wire
+// format to check what Marshal wrote.
+
+type decodedTile struct {
+	layers []decodedLayer
+}
+
+type decodedLayer struct {
+	version  uint64
+	name     string
+	features []decodedFeature
+	keys     []string
+	values   []any
+	extent   uint64
+}
+
+type decodedFeature struct {
+	id       uint64
+	tags     []uint32
+	geomType uint64
+	geometry []uint32
+}
+
+// protoField is one decoded field: varint and fixed64 values in num, length
+// delimited ones in bytes
+type protoField struct {
+	number int
+	num    uint64
+	bytes  []byte
+}
+
+func readFields(t *testing.T, buf []byte) []protoField {
+	t.Helper()
+	var fields []protoField
+	for len(buf) > 0 {
+		key, n := binary.Uvarint(buf)
+		if n <= 0 {
+			t.Fatalf("bad field key")
+		}
+		buf = buf[n:]
+		field := protoField{number: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			field.num, n = binary.Uvarint(buf)
+			if n <= 0 {
+				t.Fatalf("bad varint in field %d", field.number)
+			}
+			buf = buf[n:]
+		case 1:
+			field.num = binary.LittleEndian.Uint64(buf)
+			buf = buf[8:]
+		case 2:
+			size, n := binary.Uvarint(buf)
+			if n <= 0 || int(size) > len(buf)-n {
+				t.Fatalf("bad length in field %d", field.number)
+			}
+			field.bytes = buf[n : n+int(size)]
+			buf = buf[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func readPacked(t *testing.T, buf []byte) []uint32 {
+	t.Helper()
+	var values []uint32
+	for len(buf) > 0 {
+		v, n := binary.Uvarint(buf)
+		if n <= 0 {
+			t.Fatal("bad packed varint")
+		}
+		values = append(values, uint32(v))
+		buf = buf[n:]
+	}
+	return values
+}
+
+func decodeTile(t *testing.T, data []byte) decodedTile {
+	t.Helper()
+	var tile decodedTile
+	for _, f := range readFields(t, data) {
+		if f.number != 3 {
+			t.Fatalf("unexpected tile field %d", f.number)
+		}
+		tile.layers = append(tile.layers, decodeLayer(t, f.bytes))
+	}
+	return tile
+}
+
+func decodeLayer(t *testing.T, data []byte) decodedLayer {
+	t.Helper()
+	var layer decodedLayer
+	for _, f := range readFields(t, data) {
+		switch f.number {
+		case 15:
+			layer.version = f.num
+		case 1:
+			layer.name = string(f.bytes)
+		case 2:
+			layer.features = append(layer.features, decodeFeature(t, f.bytes))
+		case 3:
+			layer.keys = append(layer.keys, string(f.bytes))
+		case 4:
+			layer.values = append(layer.values, decodeValue(t, f.bytes))
+		case 5:
+			layer.extent = f.num
+		default:
+			t.Fatalf("unexpected layer field %d", f.number)
+		}
+	}
+	return layer
+}
+
+func decodeFeature(t *testing.T, data []byte) decodedFeature {
+	t.Helper()
+	var feature decodedFeature
+	for _, f := range readFields(t, data) {
+		switch f.number {
+		case 1:
+			feature.id = f.num
+		case 2:
+			feature.tags = readPacked(t, f.bytes)
+		case 3:
+			feature.geomType = f.num
+		case 4:
+			feature.geometry = readPacked(t, f.bytes)
+		default:
+			t.Fatalf("unexpected feature field %d", f.number)
+		}
+	}
+	return feature
+}
+
+func decodeValue(t *testing.T, data []byte) any {
+	t.Helper()
+	fields := readFields(t, data)
+	if len(fields) != 1 {
+		t.Fatalf("value has %d fields, want 1", len(fields))
+	}
+	f := fields[0]
+	switch f.number {
+	case 1:
+		return string(f.bytes)
+	case 3:
+		return math.Float64frombits(f.num)
+	case 4:
+		return int64(f.num)
+	case 5:
+		return f.num
+	case 6:
+		return int64(f.num>>1) ^ -int64(f.num&1)
+	case 7:
+		return f.num != 0
+	}
+	t.Fatalf("unexpected value field %d", f.number)
+	return nil
+}
+
+// decodePaths replays geometry commands into absolute paths, one per MoveTo
+func decodePaths(t *testing.T, geometry []uint32) (paths [][]Point, closed []bool) {
+	t.Helper()
+	var cursor Point
+	for i := 0; i < len(geometry); {
+		id, count := geometry[i]&7, geometry[i]>>3
+		i++
+		switch id {
+		case cmdMoveTo, cmdLineTo:
+			if id == cmdMoveTo {
+				paths = append(paths, nil)
+				closed = append(closed, false)
+			}
+			for range count {
+				dx, dy := unzigzag(geometry[i]), unzigzag(geometry[i+1])
+				i += 2
+				cursor = Point{X: cursor.X + dx, Y: cursor.Y + dy}
+				paths[len(paths)-1] = append(paths[len(paths)-1], cursor)
+			}
+		case cmdClosePath:
+			closed[len(closed)-1] = true
+		default:
+			t.Fatalf("unknown command %d", id)
+		}
+	}
+	return paths, closed
+}
+
+func unzigzag(v uint32) int32 {
+	return int32(v>>1) ^ -int32(v&1)
+}
+
+func TestLineStringGeometry(t *testing.T) {
+	layer := NewLayer("routes", DefaultExtent)
+	layer.AddLineString(7, []Point{{1, 1}, {1, 1}, {5, 1}, {5, -3}}, nil)
+
+	tile := decodeTile(t, (&Tile{Layers: []*Layer{layer}}).Marshal())
+	f := tile.layers[0].features[0]
+	if f.id != 7 || f.geomType != geomLineString {
+		t.Errorf("feature id %d, type %d; want 7, LineString", f.id, f.geomType)
+	}
+	// MoveTo(1) +1,+1; LineTo(2) +4,0 then 0,-4, with the repeated point dropped
+	if want := []uint32{9, 2, 2, 18, 8, 0, 0, 7}; !slices.Equal(f.geometry, want) {
+		t.Errorf("geometry = %v, want %v", f.geometry, want)
+	}
+}
+
+func TestPolygonGeometry(t *testing.T) {
+	layer := NewLayer("h3", DefaultExtent)
+	// Counterclockwise on screen (y down) and closed: the encoder must reverse
+	// it and drop the repeated closing point
+	layer.AddPolygon(1, [][]Point{{{0, 0}, {0, 10}, {10, 10}, {10, 0}, {0, 0}}}, nil)
+
+	tile := decodeTile(t, (&Tile{Layers: []*Layer{layer}}).Marshal())
+	f := tile.layers[0].features[0]
+	if f.geomType != geomPolygon {
+		t.Fatalf("type = %d, want Polygon", f.geomType)
+	}
+	want := []uint32{
+		9, 20, 0, // MoveTo(1) 10,0
+		26, 0, 20, 19, 0, 0, 19, // LineTo(3) 10,10  0,10  0,0
+		15, // ClosePath
+	}
+	if !slices.Equal(f.geometry, want) {
+		t.Errorf("geometry = %v, want %v", f.geometry, want)
+	}
+}
+
+func TestPolygonWinding(t *testing.T) {
+	exteriorCW := []Point{{0, 0}, {100, 0}, {100, 100}, {0, 100}}
+	holeCW := []Point{{20, 20}, {80, 20}, {80, 80}, {20, 80}}
+
+	for name, rings := range map[string][][]Point{
+		"as given": {exteriorCW, holeCW},
+		"reversed": {reversed(exteriorCW), reversed(holeCW)},
+	} {
+		layer := NewLayer("h3", DefaultExtent)
+		layer.AddPolygon(1, rings, nil)
+		tile := decodeTile(t, (&Tile{Layers: []*Layer{layer}}).Marshal())
+
+		paths, closed := decodePaths(t, tile.layers[0].features[0].geometry)
+		if len(paths) != 2 || !closed[0] || !closed[1] {
+			t.Fatalf("%s: %d rings (closed %v), want 2 closed", name, len(paths), closed)
+		}
+		// Exterior rings are clockwise (positive area with y down), holes counterclockwise
+		if a := signedArea(paths[0]); a <= 0 {
+			t.Errorf("%s: exterior area %d, want positive", name, a)
+		}
+		if a := signedArea(paths[1]); a >= 0 {
+			t.Errorf("%s: hole area %d, want negative", name, a)
+		}
+	}
+}
+
+func TestDegenerateGeometrySkipped(t *testing.T) {
+	layer := NewLayer("x", DefaultExtent)
+	layer.AddLineString(1, []Point{{3, 3}, {3, 3}}, nil)
+	layer.AddPolygon(2, [][]Point{{{0, 0}, {5, 5}, {10, 10}}}, nil) // collinear
+	layer.AddPolygon(3, [][]Point{{{0, 0}, {5, 0}}}, nil)
+	if layer.Len() != 0 {
+		t.Errorf("layer has %d features, want degenerate ones skipped", layer.Len())
+	}
+
+	// Empty layers are left out of the tile
+	if data := (&Tile{Layers: []*Layer{layer}}).Marshal(); len(data) != 0 {
+		t.Errorf("tile with only an empty layer encoded %d bytes", len(data))
+	}
+}
+
+func TestLayerProperties(t *testing.T) {
+	layer := NewLayer("locations", 512)
+	props := map[string]any{
+		"name":     "Kyoto",
+		"distance": 12.5,
+		"offset":   -3,
+		"count":    uint32(4),
+		"visited":  true,
+		"ignored":  []int{1},
+	}
+	layer.AddPoint(1, Point{X: 10, Y: 20}, props)
+	layer.AddPoint(2, Point{X: 30, Y: 40}, map[string]any{"name": "Kyoto", "visited": false})
+
+	tile := decodeTile(t, (&Tile{Layers: []*Layer{layer}}).Marshal())
+	if len(tile.layers) != 1 {
+		t.Fatalf("%d layers, want 1", len(tile.layers))
+	}
+	l := tile.layers[0]
+	if l.version != 2 || l.name != "locations" || l.extent != 512 {
+		t.Errorf("layer version %d, name %q, extent %d", l.version, l.name, l.extent)
+	}
+
+	// Keys are sorted per feature and shared; values are deduplicated
+	if want := []string{"count", "distance", "name", "offset", "visited"}; !slices.Equal(l.keys, want) {
+		t.Errorf("keys = %v, want %v", l.keys, want)
+	}
+	if want := []any{uint64(4), 12.5, "Kyoto", int64(-3), true, false}; !slices.Equal(l.values, want) {
+		t.Errorf("values = %v, want %v", l.values, want)
+	}
+	if want := []uint32{0, 0, 1, 1, 2, 2, 3, 3, 4, 4}; !slices.Equal(l.features[0].tags, want) {
+		t.Errorf("first feature tags = %v, want %v", l.features[0].tags, want)
+	}
+	if want := []uint32{2, 2, 4, 5}; !slices.Equal(l.features[1].tags, want) {
+		t.Errorf("second feature tags = %v, want %v", l.features[1].tags, want)
+	}
+
+	if f := l.features[1]; f.geomType != geomPoint || !slices.Equal(f.geometry, []uint32{9, 60, 80}) {
+		t.Errorf("point feature type %d, geometry %v", f.geomType, f.geometry)
+	}
+}
@@ -0,0 +1,75 @@
+package mvt
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxMercatorLat is the latitude limit of the Web Mercator projection
+const maxMercatorLat = 85.05112878
+
+// TileID identifies a tile in the XYZ (slippy map) scheme
+type TileID struct {
+	Z, X, Y uint32
+}
+
+// ParseTileID parses z, x and y path segments; y may carry a ".mvt" suffix
+func ParseTileID(z, x, y string) (TileID, error) {
+	y = strings.TrimSuffix(y, ".mvt")
+
+	zv, err := strconv.ParseUint(z, 10, 32)
+	if err != nil || zv > 24 {
+		return TileID{}, fmt.Errorf("invalid zoom %q", z)
+	}
+	xv, err := strconv.ParseUint(x, 10, 32)
+	if err != nil || xv >= 1<<zv {
+		return TileID{}, fmt.Errorf("invalid x %q for zoom %d", x, zv)
+	}
+	yv, err := strconv.ParseUint(y, 10, 32)
+	if err != nil || yv >= 1<<zv {
+		return TileID{}, fmt.Errorf("invalid y %q for zoom %d", y, zv)
+	}
+
+	return TileID{Z: uint32(zv), X: uint32(xv), Y: uint32(yv)}, nil
+}
+
+// String returns the tile as "z/x/y"
+func (t TileID) String() string {
+	return fmt.Sprintf("%d/%d/%d", t.Z, t.X, t.Y)
+}
+
+// Bounds returns the tile's geographic bounding box
+func (t TileID) Bounds() (minLng, minLat, maxLng, maxLat float64) {
+	n := math.Exp2(float64(t.Z))
+	minLng = float64(t.X)/n*360 - 180
+	maxLng = float64(t.X+1)/n*360 - 180
+	maxLat = tileYToLat(float64(t.Y), n)
+	minLat = tileYToLat(float64(t.Y+1), n)
+	return
+}
+
+// Project converts a lng/lat position to this tile's coordinate space.
+// Positions outside the tile map outside 0..extent.
+func (t TileID) Project(lng, lat float64, extent uint32) Point {
+	x, y := t.ProjectFloat(lng, lat, extent)
+	return Point{X: int32(math.Round(x)), Y: int32(math.Round(y))}
+}
+
+// ProjectFloat is Project without rounding, for callers that simplify or clip first
+func (t TileID) ProjectFloat(lng, lat float64, extent uint32) (x, y float64) {
+	lat = math.Max(-maxMercatorLat, math.Min(maxMercatorLat, lat))
+	n := math.Exp2(float64(t.Z))
+	e := float64(extent)
+
+	worldX := (lng + 180) / 360 * n
+	sin := math.Sin(lat * math.Pi / 180)
+	worldY := (0.5 - math.Log((1+sin)/(1-sin))/(4*math.Pi)) * n
+
+	return (worldX - float64(t.X)) * e, (worldY - float64(t.Y)) * e
+}
+
+func tileYToLat(y, n float64) float64 {
+	return math.Atan(math.Sinh(math.Pi*(1-2*y/n))) * 180 / math.Pi
+}
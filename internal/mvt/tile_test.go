@@ -0,0 +1,73 @@
+package mvt
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseTileID(t *testing.T) {
+	tests := []struct {
+		z, x, y string
+		want    TileID
+	}{
+		{"0", "0", "0", TileID{0, 0, 0}},
+		{"7", "113", "50.mvt", TileID{7, 113, 50}},
+		{"24", "16777215", "16777215", TileID{24, 1<<24 - 1, 1<<24 - 1}},
+	}
+	for _, tt := range tests {
+		got, err := ParseTileID(tt.z, tt.x, tt.y)
+		if err != nil {
+			t.Errorf("ParseTileID(%s, %s, %s): %v", tt.z, tt.x, tt.y, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTileID(%s, %s, %s) = %v, want %v", tt.z, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestParseTileIDInvalid(t *testing.T) {
+	tests := [][3]string{
+		{"25", "0", "0"}, // past the 24 zoom limit
+		{"-1", "0", "0"}, // negative zoom
+		{"2", "4", "0"},  // x out of range
+		{"2", "0", "4"},  // y out of range
+		{"2", "0", "1.png"},
+		{"z", "0", "0"},
+		{"2", "0", ""},
+	}
+	for _, tt := range tests {
+		if got, err := ParseTileID(tt[0], tt[1], tt[2]); err == nil {
+			t.Errorf("ParseTileID(%s, %s, %s) = %v, want an error", tt[0], tt[1], tt[2], got)
+		}
+	}
+}
+
+func TestTileString(t *testing.T) {
+	if got := (TileID{Z: 7, X: 113, Y: 50}).String(); got != "7/113/50" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestBoundsAndProject(t *testing.T) {
+	world := TileID{}
+	minLng, minLat, maxLng, maxLat := world.Bounds()
+	if minLng != -180 || maxLng != 180 || math.Abs(maxLat-maxMercatorLat) > 1e-6 || math.Abs(minLat+maxMercatorLat) > 1e-6 {
+		t.Errorf("world bounds = %v,%v,%v,%v", minLng, minLat, maxLng, maxLat)
+	}
+
+	// The corners of a tile project to the corners of its extent
+	tile := TileID{Z: 7, X: 113, Y: 50}
+	minLng, minLat, maxLng, maxLat = tile.Bounds()
+	if p := tile.Project(minLng, maxLat, DefaultExtent); p != (Point{0, 0}) {
+		t.Errorf("top left projects to %v, want 0,0", p)
+	}
+	if p := tile.Project(maxLng, minLat, DefaultExtent); p != (Point{DefaultExtent, DefaultExtent}) {
+		t.Errorf("bottom right projects to %v, want %d,%d", p, DefaultExtent, DefaultExtent)
+	}
+
+	// Latitudes past the Mercator limit are clamped rather than becoming infinite
+	if x, y := world.ProjectFloat(0, 90, DefaultExtent); math.IsInf(y, 0) || math.IsNaN(y) || x != DefaultExtent/2 {
+		t.Errorf("pole projects to %v,%v", x, y)
+	}
+}
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/uber/h3-go/v4"
+)
+
+// Query parameter helpers. Each reads one parameter, writes a 400 APIError
+// naming the parameter in Field when it is missing (ErrCodeRequired), malformed
+// (ErrCodeInvalidValue) or outside its allowed range (ErrCodeOutOfRange), and
+// reports whether the handler may continue.
+
+// writeParamError sends a 400 for an invalid request parameter (query, path or body field)
+func writeParamError(w http.ResponseWriter, code, field, message string) {
+	writeError(w, http.StatusBadRequest, APIError{
+		Code:    code,
+		Field:   field,
+		Message: message,
+	})
+}
+
+// queryFloat reads a required number within [min, max]
+func queryFloat(w http.ResponseWriter, r *http.Request, name string, min, max float64) (float64, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		writeParamError(w, ErrCodeRequired, name, fmt.Sprintf("%s parameter required", name))
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		writeParamError(w, ErrCodeInvalidValue, name, fmt.Sprintf("%s must be a number, got %q", name, raw))
+		return 0, false
+	}
+	if v < min || v > max {
+		writeParamError(w, ErrCodeOutOfRange, name, fmt.Sprintf("%s must be between %g and %g", name, min, max))
+		return 0, false
+	}
+	return v, true
+}
+
+// queryLat reads a required latitude in degrees
+func queryLat(w http.ResponseWriter, r *http.Request, name string) (float64, bool) {
+	return queryFloat(w, r, name, -90, 90)
+}
+
+// queryLng reads a required longitude in degrees
+func queryLng(w http.ResponseWriter, r *http.Request, name string) (float64, bool) {
+	return queryFloat(w, r, name, -180, 180)
+}
+
+// queryBBox reads the required minLat, minLng, maxLat and maxLng parameters
+func queryBBox(w http.ResponseWriter, r *http.Request) (BBox, bool) {
+	var bbox BBox
+	var ok bool
+	if bbox.MinLat, ok = queryLat(w, r, "minLat"); !ok {
+		return bbox, false
+	}
+	if bbox.MinLng, ok = queryLng(w, r, "minLng"); !ok {
+		return bbox, false
+	}
+	if bbox.MaxLat, ok = queryLat(w, r, "maxLat"); !ok {
+		return bbox, false
+	}
+	if bbox.MaxLng, ok = queryLng(w, r, "maxLng"); !ok {
+		return bbox, false
+	}
+
+	if bbox.MinLat > bbox.MaxLat {
+		writeParamError(w, ErrCodeOutOfRange, "minLat", "minLat must be <= maxLat")
+		return bbox, false
+	}
+	if bbox.MinLng > bbox.MaxLng {
+		writeParamError(w, ErrCodeOutOfRange, "minLng", "minLng must be <= maxLng")
+		return bbox, false
+	}
+	return bbox, true
+}
+
+// queryInt reads an optional integer within [min, max], returning def when absent
+func queryInt(w http.ResponseWriter, r *http.Request, name string, def, min, max int) (int, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, true
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		writeParamError(w, ErrCodeInvalidValue, name, fmt.Sprintf("%s must be an integer, got %q", name, raw))
+		return 0, false
+	}
+	if v < min || v > max {
+		writeParamError(w, ErrCodeOutOfRange, name, fmt.Sprintf("%s must be between %d and %d", name, min, max))
+		return 0, false
+	}
+	return v, true
+}
+
+// queryResolution reads an optional H3 resolution, returning def when absent
+func queryResolution(w http.ResponseWriter, r *http.Request, def int) (int, bool) {
+	return queryInt(w, r, "resolution", def, 0, h3.MaxResolution)
+}
+
+// queryBool reads an optional boolean (true/false, 1/0), false when absent
+func queryBool(w http.ResponseWriter, r *http.Request, name string) (bool, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return false, true
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		writeParamError(w, ErrCodeInvalidValue, name, fmt.Sprintf("%s must be true or false", name))
+		return false, false
+	}
+	return v, true
+}
+
+// queryCell reads a required H3 index
+func queryCell(w http.ResponseWriter, r *http.Request, name string) (h3.Cell, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		writeParamError(w, ErrCodeRequired, name, fmt.Sprintf("%s parameter required", name))
+		return 0, false
+	}
+	return parseCell(w, name, raw)
+}
+
+// parseCell validates an H3 index given in the named parameter, path segment or
+// body field. Hex digits may be in either case, but nothing else (such as a
+// 0x prefix or leading zeros) that would change the index's canonical string.
+func parseCell(w http.ResponseWriter, field, raw string) (h3.Cell, bool) {
+	cell := h3.Cell(h3.IndexFromString(raw))
+	if !cell.IsValid() || cell.String() != strings.ToLower(raw) {
+		writeParamError(w, ErrCodeInvalidValue, field, fmt.Sprintf("invalid H3 index %q", raw))
+		return 0, false
+	}
+	return cell, true
+}
+
+// parseCells validates a list of H3 indexes from the named field
+func parseCells(w http.ResponseWriter, field string, raw []string) ([]h3.Cell, bool) {
+	cells := make([]h3.Cell, len(raw))
+	for i, index := range raw {
+		cell, ok := parseCell(w, field, strings.TrimSpace(index))
+		if !ok {
+			return nil, false
+		}
+		cells[i] = cell
+	}
+	return cells, true
+}
@@ -0,0 +1,483 @@
+package internal
+
+import (
+	"archive/zip"
+	"container/heap"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// railSnapMaxKm is how far a waypoint may be from the nearest track
+const railSnapMaxKm = 2.0
+
+// railJunctionKm is the distance within which points of different GTFS shapes
+// are joined. Shapes of different routes rarely share exact coordinates, so
+// without this they would form disconnected lines.
+const railJunctionKm = 0.05
+
+// railJunctionCellDeg is the grid cell size used to find nearby points when
+// joining shapes; it must cover at least railJunctionKm in both directions
+const railJunctionCellDeg = 0.001
+
+// defaultRailSpeedKmh is the average speed of trains routed along track, set
+// for the Shinkansen (Tokyo to Shin-Osaka, 513 km, takes about 2h15m)
+const defaultRailSpeedKmh = 230.0
+
+// railwayTypes are the OSM railway=* values loaded as track
+var railwayTypes = []string{"rail", "light_rail", "subway", "narrow_gauge", "monorail"}
+
+// railEdge is a track segment to a neighboring point
+type railEdge struct {
+	to int32
+	km float64
+}
+
+// RailNetwork is a graph of rail track: points along the track joined by the
+// segments between them.
+type RailNetwork struct {
+	points  [][2]float64 // [lat, lng]
+	edges   [][]railEdge
+	byCoord map[[2]float64]int32
+}
+
+// newRailNetwork returns an empty network
+func newRailNetwork() *RailNetwork {
+	return &RailNetwork{byCoord: make(map[[2]float64]int32)}
+}
+
+// Len returns the number of track points in the network
+func (n *RailNetwork) Len() int {
+	return len(n.points)
+}
+
+// addPoint returns the index of the point at lat, lng, adding it if new
+func (n *RailNetwork) addPoint(lat, lng float64) int32 {
+	key := [2]float64{lat, lng}
+	if i, ok := n.byCoord[key]; ok {
+		return i
+	}
+	i := int32(len(n.points))
+	n.points = append(n.points, key)
+	n.edges = append(n.edges, nil)
+	n.byCoord[key] = i
+	return i
+}
+
+// addEdge joins two points with a track segment in both directions
+func (n *RailNetwork) addEdge(a, b int32) {
+	if a == b || slices.ContainsFunc(n.edges[a], func(e railEdge) bool { return e.to == b }) {
+		return
+	}
+	pa, pb := n.points[a], n.points[b]
+	km := haversineKm(pa[0], pa[1], pb[0], pb[1])
+	n.edges[a] = append(n.edges[a], railEdge{to: b, km: km})
+	n.edges[b] = append(n.edges[b], railEdge{to: a, km: km})
+}
+
+// addLine adds a polyline of [lat, lng] points as connected track
+func (n *RailNetwork) addLine(line [][2]float64) {
+	prev := int32(-1)
+	for _, p := range line {
+		i := n.addPoint(p[0], p[1])
+		if prev >= 0 {
+			n.addEdge(prev, i)
+		}
+		prev = i
+	}
+}
+
+// joinNearby connects points closer than maxKm, using a grid of
+// railJunctionCellDeg cells so each point is only compared with its neighbors
+func (n *RailNetwork) joinNearby(maxKm float64) {
+	type cellKey struct{ lat, lng int64 }
+	cellOf := func(p [2]float64) cellKey {
+		return cellKey{int64(math.Floor(p[0] / railJunctionCellDeg)), int64(math.Floor(p[1] / railJunctionCellDeg))}
+	}
+
+	grid := make(map[cellKey][]int32)
+	for i, p := range n.points {
+		cell := cellOf(p)
+		grid[cell] = append(grid[cell], int32(i))
+	}
+
+	for i, p := range n.points {
+		cell := cellOf(p)
+		for dLat := int64(-1); dLat <= 1; dLat++ {
+			for dLng := int64(-1); dLng <= 1; dLng++ {
+				for _, j := range grid[cellKey{cell.lat + dLat, cell.lng + dLng}] {
+					if j <= int32(i) {
+						continue
+					}
+					q := n.points[j]
+					if haversineKm(p[0], p[1], q[0], q[1]) <= maxKm {
+						n.addEdge(int32(i), j)
+					}
+				}
+			}
+		}
+	}
+}
+
+// LoadRailNetwork loads rail track from an OSM XML extract (.osm) or from the
+// shapes.txt of a GTFS feed, given as the file itself, the feed directory or
+// the feed .zip. Every GTFS shape is treated as track, so use a rail operator's
+// feed rather than one that also has bus routes. OSM extracts are best filtered
+// to railway ways first (e.g. osmium tags-filter extract.osm.pbf w/railway -o
+// rail.osm), since every node in the file is held in memory while reading.
+func LoadRailNetwork(path string) (*RailNetwork, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("rail network: %w", err)
+	}
+	if info.IsDir() {
+		path = filepath.Join(path, "shapes.txt")
+	}
+
+	var network *RailNetwork
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".osm":
+		network, err = loadFile(path, readOSMRail)
+	case ".txt":
+		network, err = loadFile(path, readGTFSShapes)
+	case ".zip":
+		network, err = loadGTFSZip(path)
+	default:
+		return nil, fmt.Errorf("rail network %s: unsupported format (use .osm, a GTFS shapes.txt, feed directory or .zip)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rail network %s: %w", path, err)
+	}
+	if network.Len() == 0 {
+		return nil, fmt.Errorf("rail network %s: no track found", path)
+	}
+	return network, nil
+}
+
+// loadFile opens path and parses it with read
+func loadFile(path string, read func(io.Reader) (*RailNetwork, error)) (*RailNetwork, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return read(file)
+}
+
+// loadGTFSZip reads shapes.txt from a zipped GTFS feed
+func loadGTFSZip(path string) (*RailNetwork, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	file, err := archive.Open("shapes.txt")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readGTFSShapes(file)
+}
+
+// gtfsShapePoint is one row of shapes.txt
+type gtfsShapePoint struct {
+	sequence int
+	lat, lng float64
+}
+
+// readGTFSShapes builds a network from GTFS shapes.txt, one line per shape_id
+// with points in shape_pt_sequence order
+func readGTFSShapes(r io.Reader) (*RailNetwork, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading shapes.txt header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Feeds written on Windows often start with a byte order mark
+		columns[strings.TrimPrefix(strings.TrimSpace(name), "\ufeff")] = i
+	}
+	for _, name := range []string{"shape_id", "shape_pt_lat", "shape_pt_lon", "shape_pt_sequence"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("shapes.txt: missing column %s", name)
+		}
+	}
+
+	shapes := make(map[string][]gtfsShapePoint)
+	var order []string
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("shapes.txt: %w", err)
+		}
+
+		lat, errLat := strconv.ParseFloat(record[columns["shape_pt_lat"]], 64)
+		lng, errLng := strconv.ParseFloat(record[columns["shape_pt_lon"]], 64)
+		sequence, errSeq := strconv.Atoi(record[columns["shape_pt_sequence"]])
+		if err := errors.Join(errLat, errLng, errSeq); err != nil {
+			return nil, fmt.Errorf("shapes.txt:%d: %w", line, err)
+		}
+
+		id := record[columns["shape_id"]]
+		if _, ok := shapes[id]; !ok {
+			order = append(order, id)
+		}
+		shapes[id] = append(shapes[id], gtfsShapePoint{sequence: sequence, lat: lat, lng: lng})
+	}
+
+	network := newRailNetwork()
+	for _, id := range order {
+		points := shapes[id]
+		slices.SortStableFunc(points, func(a, b gtfsShapePoint) int { return a.sequence - b.sequence })
+		line := make([][2]float64, len(points))
+		for i, p := range points {
+			line[i] = [2]float64{p.lat, p.lng}
+		}
+		network.addLine(line)
+	}
+	network.joinNearby(railJunctionKm)
+	return network, nil
+}
+
+// readOSMRail builds a network from the railway ways of an OSM XML file.
+// Ways that share a node are connected through it.
+func readOSMRail(r io.Reader) (*RailNetwork, error) {
+	decoder := xml.NewDecoder(r)
+	nodes := make(map[int64][2]float64)
+	network := newRailNetwork()
+
+	var refs []int64
+	var railway string
+	inWay := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "node":
+				var id int64
+				var lat, lng float64
+				for _, attr := range el.Attr {
+					switch attr.Name.Local {
+					case "id":
+						id, _ = strconv.ParseInt(attr.Value, 10, 64)
+					case "lat":
+						lat, _ = strconv.ParseFloat(attr.Value, 64)
+					case "lon":
+						lng, _ = strconv.ParseFloat(attr.Value, 64)
+					}
+				}
+				nodes[id] = [2]float64{lat, lng}
+			case "way":
+				inWay, refs, railway = true, refs[:0], ""
+			case "nd":
+				if inWay {
+					for _, attr := range el.Attr {
+						if attr.Name.Local == "ref" {
+							ref, _ := strconv.ParseInt(attr.Value, 10, 64)
+							refs = append(refs, ref)
+						}
+					}
+				}
+			case "tag":
+				if inWay && attrValue(el, "k") == "railway" {
+					railway = attrValue(el, "v")
+				}
+			}
+		case xml.EndElement:
+			if el.Name.Local != "way" {
+				continue
+			}
+			inWay = false
+			if !slices.Contains(railwayTypes, railway) {
+				continue
+			}
+			// Nodes missing from the extract split the way
+			var line [][2]float64
+			for _, ref := range refs {
+				p, ok := nodes[ref]
+				if !ok {
+					network.addLine(line)
+					line = line[:0]
+					continue
+				}
+				line = append(line, p)
+			}
+			network.addLine(line)
+		}
+	}
+	return network, nil
+}
+
+// attrValue returns the value of an element's attribute, or "" if absent
+func attrValue(el xml.StartElement, name string) string {
+	for _, attr := range el.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// nearest returns the track point closest to lat, lng and its distance in km
+func (n *RailNetwork) nearest(lat, lng float64) (int32, float64) {
+	best, bestKm := int32(-1), math.Inf(1)
+	for i, p := range n.points {
+		if km := haversineKm(lat, lng, p[0], p[1]); km < bestKm {
+			best, bestKm = int32(i), km
+		}
+	}
+	return best, bestKm
+}
+
+// railQueueItem is a point waiting in the shortest-path queue
+type railQueueItem struct {
+	point int32
+	km    float64
+}
+
+// railQueue is a min-heap of points by distance from the start
+type railQueue []railQueueItem
+
+func (q railQueue) Len() int           { return len(q) }
+func (q railQueue) Less(i, j int) bool { return q[i].km < q[j].km }
+func (q railQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *railQueue) Push(x any)        { *q = append(*q, x.(railQueueItem)) }
+func (q *railQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// shortestPath returns the points along the shortest track from a to b
+// (Dijkstra) and its length in km, or false if no track connects them
+func (n *RailNetwork) shortestPath(a, b int32) ([]int32, float64, bool) {
+	dist := make([]float64, len(n.points))
+	for i := range dist {
+		dist[i] = math.Inf(1)
+	}
+	prev := make([]int32, len(n.points))
+	dist[a] = 0
+	prev[a] = -1
+
+	queue := &railQueue{{point: a}}
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(railQueueItem)
+		if item.point == b {
+			break
+		}
+		if item.km > dist[item.point] {
+			continue
+		}
+		for _, edge := range n.edges[item.point] {
+			if km := item.km + edge.km; km < dist[edge.to] {
+				dist[edge.to] = km
+				prev[edge.to] = item.point
+				heap.Push(queue, railQueueItem{point: edge.to, km: km})
+			}
+		}
+	}
+
+	if math.IsInf(dist[b], 1) {
+		return nil, 0, false
+	}
+	var path []int32
+	for p := b; p != -1; p = prev[p] {
+		path = append(path, p)
+	}
+	slices.Reverse(path)
+	return path, dist[b], true
+}
+
+// RailRouting routes trains along a RailNetwork, snapping each waypoint to the
+// nearest track. Other modes go to Fallback.
+type RailRouting struct {
+	Network  *RailNetwork
+	Fallback RoutingProvider // for modes other than "train"; may be nil
+	SpeedKmh float64         // average train speed; 0 uses defaultRailSpeedKmh
+}
+
+// Name implements RoutingProvider
+func (p *RailRouting) Name() string {
+	if p.Fallback != nil {
+		return "rail+" + p.Fallback.Name()
+	}
+	return "rail"
+}
+
+// Route implements RoutingProvider. The geometry follows the track between
+// the snapped waypoints; the duration is the track distance at SpeedKmh.
+func (p *RailRouting) Route(ctx context.Context, mode string, waypoints []Location) (*RouteResult, error) {
+	if mode != "train" {
+		if p.Fallback == nil {
+			return nil, fmt.Errorf("rail: cannot route %q", mode)
+		}
+		return p.Fallback.Route(ctx, mode, waypoints)
+	}
+	if len(waypoints) < 2 {
+		return nil, errTooFewWaypoints
+	}
+
+	snapped := make([]int32, len(waypoints))
+	for i, wp := range waypoints {
+		point, km := p.Network.nearest(wp.Lat, wp.Lng)
+		if km > railSnapMaxKm {
+			return nil, fmt.Errorf("rail: %s is %.1f km from the nearest track", wp.Name, km)
+		}
+		snapped[i] = point
+	}
+
+	var geometry [][]float64
+	distanceKm := 0.0
+	for i := 1; i < len(snapped); i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		path, km, ok := p.Network.shortestPath(snapped[i-1], snapped[i])
+		if !ok {
+			return nil, fmt.Errorf("rail: no track connects %s and %s", waypoints[i-1].Name, waypoints[i].Name)
+		}
+		distanceKm += km
+
+		// Each leg starts where the previous one ended
+		if i > 1 {
+			path = path[1:]
+		}
+		for _, point := range path {
+			pt := p.Network.points[point]
+			geometry = append(geometry, []float64{pt[1], pt[0]})
+		}
+	}
+
+	speed := p.SpeedKmh
+	if speed <= 0 {
+		speed = defaultRailSpeedKmh
+	}
+	return &RouteResult{
+		Geometry: geometry,
+		Distance: distanceKm * 1000,
+		Duration: distanceKm / speed * 3600,
+	}, nil
+}
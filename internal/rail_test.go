@@ -0,0 +1,257 @@
+package internal
+
+import (
+	"archive/zip"
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// railOSM has two railway ways meeting at node 3, a road that would be a
+// shortcut if it were loaded, and a way whose missing node 98 splits it
+const railOSM = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6">
+  <node id="1" lat="35.000" lon="139.000"/>
+  <node id="2" lat="35.000" lon="139.010"/>
+  <node id="3" lat="35.000" lon="139.020"/>
+  <node id="4" lat="35.010" lon="139.020"/>
+  <node id="5" lat="35.020" lon="139.020"/>
+  <node id="6" lat="35.100" lon="139.000"/>
+  <node id="7" lat="35.100" lon="139.010"/>
+  <way id="10">
+    <nd ref="1"/><nd ref="2"/><nd ref="3"/>
+    <tag k="railway" v="rail"/>
+  </way>
+  <way id="11">
+    <nd ref="3"/><nd ref="4"/><nd ref="5"/>
+    <tag k="railway" v="subway"/>
+  </way>
+  <way id="12">
+    <nd ref="1"/><nd ref="5"/>
+    <tag k="highway" v="primary"/>
+  </way>
+  <way id="13">
+    <nd ref="6"/><nd ref="98"/><nd ref="7"/>
+    <tag k="railway" v="rail"/>
+  </way>
+</osm>`
+
+// railShapes has two shapes whose ends are about 20 m apart (so they are
+// joined) and a third far from both. The header has a byte order mark and
+// the rows are out of sequence order.
+const railShapes = "\ufeffshape_id,shape_pt_lat,shape_pt_lon,shape_pt_sequence\n" +
+	"east,35.0000,139.0200,3\n" +
+	"east,35.0000,139.0000,1\n" +
+	"east,35.0000,139.0100,2\n" +
+	"north,35.0002,139.0200,1\n" +
+	"north,35.0100,139.0200,2\n" +
+	"north,35.0200,139.0200,3\n" +
+	"island,36.0000,140.0000,1\n" +
+	"island,36.0100,140.0000,2\n"
+
+func loadTestRail(t *testing.T, name, content string) *RailNetwork {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	network, err := LoadRailNetwork(path)
+	if err != nil {
+		t.Fatalf("LoadRailNetwork: %v", err)
+	}
+	return network
+}
+
+// at returns a waypoint at lat, lng
+func at(name string, lat, lng float64) Location {
+	return Location{Name: name, Lat: lat, Lng: lng}
+}
+
+// trackKm is the length of a polyline of [lng, lat] points
+func trackKm(geometry [][]float64) float64 {
+	km := 0.0
+	for i := 1; i < len(geometry); i++ {
+		a, b := geometry[i-1], geometry[i]
+		km += haversineKm(a[1], a[0], b[1], b[0])
+	}
+	return km
+}
+
+func TestRailRoutingOSMJunction(t *testing.T) {
+	network := loadTestRail(t, "rail.osm", railOSM)
+	if network.Len() != 7 {
+		t.Errorf("network has %d points, want 7", network.Len())
+	}
+
+	p := &RailRouting{Network: network}
+	res, err := p.Route(context.Background(), "train", []Location{at("A", 35, 139), at("B", 35.02, 139.02)})
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+
+	// The route follows both railway ways through node 3, not the road
+	want := [][]float64{{139, 35}, {139.01, 35}, {139.02, 35}, {139.02, 35.01}, {139.02, 35.02}}
+	if !sameGeometry(res.Geometry, want) {
+		t.Errorf("geometry = %v, want %v", res.Geometry, want)
+	}
+	if wantKm := trackKm(want); math.Abs(res.Distance-wantKm*1000) > 1 {
+		t.Errorf("distance = %.0f m, want %.0f m", res.Distance, wantKm*1000)
+	}
+}
+
+func TestRailRoutingOSMMissingNodeSplitsWay(t *testing.T) {
+	p := &RailRouting{Network: loadTestRail(t, "rail.osm", railOSM)}
+	_, err := p.Route(context.Background(), "train", []Location{at("C", 35.1, 139), at("D", 35.1, 139.01)})
+	if err == nil || !strings.Contains(err.Error(), "no track connects C and D") {
+		t.Errorf("err = %v, want no track between the halves of the split way", err)
+	}
+}
+
+func TestRailRoutingGTFSJunction(t *testing.T) {
+	p := &RailRouting{Network: loadTestRail(t, "shapes.txt", railShapes)}
+	res, err := p.Route(context.Background(), "train", []Location{at("A", 35, 139), at("B", 35.02, 139.02)})
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+
+	want := [][]float64{{139, 35}, {139.01, 35}, {139.02, 35}, {139.02, 35.0002}, {139.02, 35.01}, {139.02, 35.02}}
+	if !sameGeometry(res.Geometry, want) {
+		t.Errorf("geometry = %v, want %v", res.Geometry, want)
+	}
+}
+
+func TestRailRoutingSnapDistance(t *testing.T) {
+	p := &RailRouting{Network: loadTestRail(t, "shapes.txt", railShapes)}
+	_, err := p.Route(context.Background(), "train", []Location{at("A", 35, 139), at("Far", 35.1, 139)})
+	if err == nil || !strings.Contains(err.Error(), "Far is") || !strings.Contains(err.Error(), "from the nearest track") {
+		t.Errorf("err = %v, want the far waypoint named", err)
+	}
+}
+
+func TestRailRoutingNoPath(t *testing.T) {
+	p := &RailRouting{Network: loadTestRail(t, "shapes.txt", railShapes)}
+	_, err := p.Route(context.Background(), "train", []Location{at("A", 35, 139), at("Island", 36, 140)})
+	if err == nil || !strings.Contains(err.Error(), "no track connects A and Island") {
+		t.Errorf("err = %v, want no track between A and Island", err)
+	}
+}
+
+func TestRailRoutingLegsShareVertex(t *testing.T) {
+	p := &RailRouting{Network: loadTestRail(t, "shapes.txt", railShapes)}
+	res, err := p.Route(context.Background(), "train", []Location{at("A", 35, 139), at("B", 35, 139.01), at("C", 35, 139.02)})
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+
+	want := [][]float64{{139, 35}, {139.01, 35}, {139.02, 35}}
+	if !sameGeometry(res.Geometry, want) {
+		t.Errorf("geometry = %v, want %v with the point at B once", res.Geometry, want)
+	}
+}
+
+func TestRailRoutingDuration(t *testing.T) {
+	network := loadTestRail(t, "shapes.txt", railShapes)
+	waypoints := []Location{at("A", 35, 139), at("C", 35, 139.02)}
+
+	res, err := (&RailRouting{Network: network}).Route(context.Background(), "train", waypoints)
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+	if want := res.Distance / 1000 / defaultRailSpeedKmh * 3600; math.Abs(res.Duration-want) > 1e-6 {
+		t.Errorf("duration = %v s, want %v s at the default speed", res.Duration, want)
+	}
+
+	res, err = (&RailRouting{Network: network, SpeedKmh: 100}).Route(context.Background(), "train", waypoints)
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+	if want := res.Distance / 1000 / 100 * 3600; math.Abs(res.Duration-want) > 1e-6 {
+		t.Errorf("duration = %v s, want %v s at 100 km/h", res.Duration, want)
+	}
+}
+
+func TestRailRoutingFallback(t *testing.T) {
+	network := loadTestRail(t, "shapes.txt", railShapes)
+	waypoints := []Location{at("A", 35, 139), at("Far", 36, 141)}
+
+	p := &RailRouting{Network: network, Fallback: GreatCircleRouting{}}
+	if p.Name() != "rail+greatcircle" {
+		t.Errorf("name = %q", p.Name())
+	}
+	if _, err := p.Route(context.Background(), "car", waypoints); err != nil {
+		t.Errorf("car route: %v, want it routed by the fallback", err)
+	}
+
+	if _, err := (&RailRouting{Network: network}).Route(context.Background(), "car", waypoints); err == nil {
+		t.Error("car route without a fallback succeeded")
+	}
+}
+
+func TestLoadRailNetworkGTFSFeed(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "shapes.txt"), []byte(railShapes), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fromDir, err := LoadRailNetwork(dir)
+	if err != nil {
+		t.Fatalf("LoadRailNetwork(dir): %v", err)
+	}
+
+	zipPath := filepath.Join(t.TempDir(), "feed.zip")
+	file, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := zip.NewWriter(file)
+	w, err := archive.Create("shapes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(railShapes))
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	fromZip, err := LoadRailNetwork(zipPath)
+	if err != nil {
+		t.Fatalf("LoadRailNetwork(zip): %v", err)
+	}
+	if fromDir.Len() != 8 || fromZip.Len() != 8 {
+		t.Errorf("networks have %d and %d points, want 8", fromDir.Len(), fromZip.Len())
+	}
+}
+
+func TestLoadRailNetworkErrors(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"rail.geojson": `{}`,
+		"shapes.txt":   "shape_id,shape_pt_lat,shape_pt_lon\nx,35,139\n",
+		"empty.osm":    `<osm version="0.6"></osm>`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadRailNetwork(path); err == nil {
+			t.Errorf("LoadRailNetwork(%s) succeeded", name)
+		}
+	}
+}
+
+// sameGeometry reports whether two [lng, lat] polylines match to within about a meter
+func sameGeometry(got, want [][]float64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if math.Abs(got[i][0]-want[i][0]) > 1e-5 || math.Abs(got[i][1]-want[i][1]) > 1e-5 {
+			return false
+		}
+	}
+	return true
+}
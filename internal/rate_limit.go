@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimitPruneInterval is how often idle client buckets are dropped
+const rateLimitPruneInterval = time.Minute
+
+// tokenBucket is one client's request allowance
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter is a per-client token bucket: each client may make burst
+// requests at once, refilled at perMinute requests per minute.
+type RateLimiter struct {
+	mu        sync.Mutex
+	rate      float64 // tokens per second
+	burst     float64
+	clients   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+// NewRateLimiter creates a limiter allowing perMinute requests per minute per
+// client with bursts of up to burst requests. A perMinute of 0 disables limiting.
+func NewRateLimiter(perMinute, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:      float64(perMinute) / 60,
+		burst:     float64(burst),
+		clients:   make(map[string]*tokenBucket),
+		lastPrune: time.Now(),
+	}
+}
+
+// Allow takes a token from client's bucket. When the bucket is empty it returns
+// false and how long until the next token is available.
+func (l *RateLimiter) Allow(client string) (bool, time.Duration) {
+	if l.rate <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastPrune) >= rateLimitPruneInterval {
+		l.prune(now)
+	}
+
+	bucket, ok := l.clients[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.clients[client] = bucket
+	} else {
+		bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+		bucket.last = now
+	}
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// prune drops buckets that have refilled completely, which are the same as
+// having no bucket. Caller must hold l.mu.
+func (l *RateLimiter) prune(now time.Time) {
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for client, bucket := range l.clients {
+		if now.Sub(bucket.last) >= full {
+			delete(l.clients, client)
+		}
+	}
+	l.lastPrune = now
+}
+
+// Limit wraps a handler, answering 429 with Retry-After once the requesting
+// client's bucket is empty
+func (l *RateLimiter) Limit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := l.Allow(clientIP(r))
+		if !ok {
+			seconds := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			writeError(w, http.StatusTooManyRequests, APIError{
+				Code:    ErrCodeRateLimited,
+				Message: "Too many requests; retry in " + strconv.Itoa(seconds) + "s",
+			})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// clientIP returns the address of the connecting client. The server is exposed
+// directly rather than behind a proxy, so X-Forwarded-For is not trusted.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurst(t *testing.T) {
+	l := NewRateLimiter(60, 3)
+
+	for i := range 3 {
+		if ok, _ := l.Allow("1.2.3.4"); !ok {
+			t.Fatalf("request %d of the burst was limited", i+1)
+		}
+	}
+	ok, wait := l.Allow("1.2.3.4")
+	if ok {
+		t.Fatal("request past the burst was allowed")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("wait = %v, want up to 1s at 60 per minute", wait)
+	}
+
+	if ok, _ := l.Allow("5.6.7.8"); !ok {
+		t.Error("another client was limited by the first one's bucket")
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	// 6000 per minute refills one token every 10ms
+	l := NewRateLimiter(6000, 1)
+
+	if ok, _ := l.Allow("c"); !ok {
+		t.Fatal("first request was limited")
+	}
+	if ok, _ := l.Allow("c"); ok {
+		t.Fatal("second request was allowed before a refill")
+	}
+	time.Sleep(15 * time.Millisecond)
+	if ok, _ := l.Allow("c"); !ok {
+		t.Error("request after a refill was limited")
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	l := NewRateLimiter(0, 0)
+	for range 100 {
+		if ok, _ := l.Allow("c"); !ok {
+			t.Fatal("a disabled limiter limited a request")
+		}
+	}
+}
+
+func TestRateLimiterLimit(t *testing.T) {
+	l := NewRateLimiter(1, 1)
+	handler := l.Limit(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/mapbox/geocoding?q=kyoto", nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	if w := request("10.0.0.1:1000"); w.Code != http.StatusNoContent {
+		t.Fatalf("first request status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+
+	// Same client on another port: one per minute, so the next token is ~60s away
+	w := request("10.0.0.1:2000")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+	var problem APIError
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if problem.Code != ErrCodeRateLimited || problem.Status != http.StatusTooManyRequests {
+		t.Errorf("problem = %+v", problem)
+	}
+
+	if w := request("10.0.0.2:1000"); w.Code != http.StatusNoContent {
+		t.Errorf("other client status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+}
@@ -12,6 +12,8 @@ var CachedRoutes []CachedRoute
 
 // Route represents a travel route between two points
 type Route struct {
+	ID          int64      `json:"id"`
+	TripID      int64      `json:"tripId"`
 	Name        string     `json:"name"`
 	Type        string     `json:"type"` // "train", "walk", "car", "flight"
 	Origin      Location   `json:"origin"`
@@ -23,6 +25,7 @@ type Route struct {
 
 // CachedRoute represents a route with actual geometry from Mapbox
 type CachedRoute struct {
+	RouteID     int64       `json:"routeId,omitempty"` // trip route, set by CachedRoutesFor
 	Name        string      `json:"name"`
 	Type        string      `json:"type"`
 	Origin      Location    `json:"origin"`
@@ -39,7 +42,7 @@ type Location struct {
 	Lng  float64 `json:"lng"`
 }
 
-// TripRoutes contains all routes for the Japan trip
+// TripRoutes is the seed data for the trip store's routes
 var TripRoutes = []Route{
 	{
 		Name: "Tokyo to Kyoto Shinkansen",
@@ -112,6 +115,36 @@ var TripRoutes = []Route{
 	},
 }
 
+// cachedRouteToleranceKm is how far a route's endpoints may be from a cached
+// route's and still use its geometry
+const cachedRouteToleranceKm = 0.1
+
+// CachedRoutesFor returns the cached geometry for each of the given routes that
+// has one. Geometry is matched on route type, origin and destination, so routes
+// of different trips only share it when they cover the same journey, and a
+// renamed route keeps it. The results carry the trip route's ID, name and endpoints.
+func CachedRoutesFor(routes []Route) []CachedRoute {
+	near := func(a, b Location) bool {
+		return haversineKm(a.Lat, a.Lng, b.Lat, b.Lng) <= cachedRouteToleranceKm
+	}
+
+	cached := []CachedRoute{}
+	for _, route := range routes {
+		for _, c := range CachedRoutes {
+			if c.Type != route.Type || !near(c.Origin, route.Origin) || !near(c.Destination, route.Destination) {
+				continue
+			}
+			c.RouteID = route.ID
+			c.Name = route.Name
+			c.Origin = route.Origin
+			c.Destination = route.Destination
+			cached = append(cached, c)
+			break
+		}
+	}
+	return cached
+}
+
 func init() {
 	// Load cached routes on startup
 	if err := json.Unmarshal(cachedRoutesJSON, &CachedRoutes); err != nil {
@@ -0,0 +1,274 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hunterjsb/tokygo/internal/mapbox"
+)
+
+// RoutingProvider computes a route through waypoints for a travel mode (a
+// Route.Type such as "train", "walk", "car" or "flight")
+type RoutingProvider interface {
+	Name() string
+	Route(ctx context.Context, mode string, waypoints []Location) (*RouteResult, error)
+}
+
+// RouteResult is a computed route
+type RouteResult struct {
+	Geometry [][]float64 // [lng, lat] pairs
+	Distance float64     // in meters
+	Duration float64     // in seconds
+}
+
+// CachedRoute builds the cached form of route from a computed result
+func (res *RouteResult) CachedRoute(route Route) CachedRoute {
+	return CachedRoute{
+		Name:        route.Name,
+		Type:        route.Type,
+		Origin:      route.Origin,
+		Destination: route.Destination,
+		Geometry:    res.Geometry,
+		Distance:    res.Distance,
+		Duration:    res.Duration,
+	}
+}
+
+// RouteWaypoints lists every point a route passes through, origin first
+func RouteWaypoints(route Route) []Location {
+	waypoints := []Location{route.Origin}
+	waypoints = append(waypoints, route.Waypoints...)
+	return append(waypoints, route.Destination)
+}
+
+// errTooFewWaypoints is returned for routes with fewer than two waypoints
+var errTooFewWaypoints = errors.New("routing needs at least 2 waypoints")
+
+// MapboxRouting routes with the Mapbox Directions API
+type MapboxRouting struct {
+	Client *mapbox.Client
+}
+
+// Name implements RoutingProvider
+func (p *MapboxRouting) Name() string { return "mapbox" }
+
+// Route implements RoutingProvider. Mapbox has no rail profile, so trains
+// (and anything else but walking) are routed by road.
+func (p *MapboxRouting) Route(ctx context.Context, mode string, waypoints []Location) (*RouteResult, error) {
+	if len(waypoints) < 2 {
+		return nil, errTooFewWaypoints
+	}
+
+	profile := mapbox.ProfileDriving
+	if mode == "walk" {
+		profile = mapbox.ProfileWalking
+	}
+
+	coordinates := make([]mapbox.LngLat, len(waypoints))
+	for i, wp := range waypoints {
+		coordinates[i] = mapbox.LngLat{Lng: wp.Lng, Lat: wp.Lat}
+	}
+
+	resp, err := p.Client.Directions(ctx, mapbox.DirectionsRequest{
+		Profile:     profile,
+		Coordinates: coordinates,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Routes) == 0 {
+		return nil, fmt.Errorf("mapbox: no route found (%s)", resp.Code)
+	}
+
+	route := resp.Routes[0]
+	return &RouteResult{
+		Geometry: route.Geometry.Coordinates,
+		Distance: route.Distance,
+		Duration: route.Duration,
+	}, nil
+}
+
+// osrmTimeout bounds a single OSRM request
+const osrmTimeout = 30 * time.Second
+
+// OSRMRouting routes with an OSRM-compatible HTTP server, such as a local
+// osrm-routed built from an OpenStreetMap extract
+type OSRMRouting struct {
+	BaseURL    string // e.g. http://localhost:5000
+	HTTPClient *http.Client
+}
+
+// NewOSRMRouting returns a provider for the OSRM server at baseURL
+func NewOSRMRouting(baseURL string) *OSRMRouting {
+	return &OSRMRouting{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: osrmTimeout},
+	}
+}
+
+// Name implements RoutingProvider
+func (p *OSRMRouting) Name() string { return "osrm" }
+
+// osrmResponse is the part of an OSRM route response we use
+type osrmResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Routes  []struct {
+		Geometry struct {
+			Coordinates [][]float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Distance float64 `json:"distance"`
+		Duration float64 `json:"duration"`
+	} `json:"routes"`
+}
+
+// Route implements RoutingProvider. Walks use the foot profile and everything
+// else driving; a server built with a single profile answers either way.
+func (p *OSRMRouting) Route(ctx context.Context, mode string, waypoints []Location) (*RouteResult, error) {
+	if len(waypoints) < 2 {
+		return nil, errTooFewWaypoints
+	}
+
+	profile := "driving"
+	if mode == "walk" {
+		profile = "foot"
+	}
+
+	coords := make([]string, len(waypoints))
+	for i, wp := range waypoints {
+		coords[i] = strconv.FormatFloat(wp.Lng, 'f', -1, 64) + "," + strconv.FormatFloat(wp.Lat, 'f', -1, 64)
+	}
+	query := url.Values{}
+	query.Set("overview", "full")
+	query.Set("geometries", "geojson")
+	endpoint := p.BaseURL + "/route/v1/" + profile + "/" + strings.Join(coords, ";") + "?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("osrm: %w", err)
+	}
+	httpClient := p.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("osrm: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("osrm: reading response: %w", err)
+	}
+
+	// OSRM reports errors such as NoRoute with a 400 and a JSON code
+	var osrmResp osrmResponse
+	if err := json.Unmarshal(body, &osrmResp); err != nil {
+		return nil, fmt.Errorf("osrm: %d response: %w", resp.StatusCode, err)
+	}
+	if osrmResp.Code != "Ok" {
+		return nil, fmt.Errorf("osrm: %s: %s", osrmResp.Code, osrmResp.Message)
+	}
+	if len(osrmResp.Routes) == 0 {
+		return nil, errors.New("osrm: no route found")
+	}
+
+	route := osrmResp.Routes[0]
+	return &RouteResult{
+		Geometry: route.Geometry.Coordinates,
+		Distance: route.Distance,
+		Duration: route.Duration,
+	}, nil
+}
+
+// greatCircleStepKm is the longest straight segment in a great-circle route
+const greatCircleStepKm = 10.0
+
+// averageSpeedsKmh are rough average speeds by travel mode, used to estimate
+// durations for routes computed without timetables or traffic data
+var averageSpeedsKmh = map[string]float64{
+	"walk":   5,
+	"car":    50,
+	"train":  120,
+	"flight": 700,
+}
+
+// defaultAverageSpeedKmh is the speed for modes not in averageSpeedsKmh
+const defaultAverageSpeedKmh = 50.0
+
+// estimateDuration returns the seconds needed to cover distanceKm at the mode's average speed
+func estimateDuration(mode string, distanceKm float64) float64 {
+	speed, ok := averageSpeedsKmh[mode]
+	if !ok {
+		speed = defaultAverageSpeedKmh
+	}
+	return distanceKm / speed * 3600
+}
+
+// GreatCircleRouting draws routes as great-circle arcs between consecutive
+// waypoints. It needs no network access, making it a fallback when no routing
+// service is available.
+type GreatCircleRouting struct{}
+
+// Name implements RoutingProvider
+func (GreatCircleRouting) Name() string { return "greatcircle" }
+
+// Route implements RoutingProvider. Duration is the distance at the mode's
+// average speed.
+func (GreatCircleRouting) Route(ctx context.Context, mode string, waypoints []Location) (*RouteResult, error) {
+	if len(waypoints) < 2 {
+		return nil, errTooFewWaypoints
+	}
+
+	geometry := [][]float64{{waypoints[0].Lng, waypoints[0].Lat}}
+	distanceKm := 0.0
+	for i := 1; i < len(waypoints); i++ {
+		from, to := waypoints[i-1], waypoints[i]
+		legKm := haversineKm(from.Lat, from.Lng, to.Lat, to.Lng)
+		distanceKm += legKm
+
+		steps := max(1, int(math.Ceil(legKm/greatCircleStepKm)))
+		for step := 1; step <= steps; step++ {
+			lat, lng := greatCirclePoint(from, to, legKm/earthRadiusKm, float64(step)/float64(steps))
+			geometry = append(geometry, []float64{lng, lat})
+		}
+	}
+
+	return &RouteResult{
+		Geometry: geometry,
+		Distance: distanceKm * 1000,
+		Duration: estimateDuration(mode, distanceKm),
+	}, nil
+}
+
+// greatCirclePoint returns the point a fraction f of the way from a to b along
+// the great circle, where δ is the angular distance between them in radians
+func greatCirclePoint(a, b Location, δ, f float64) (lat, lng float64) {
+	if f >= 1 {
+		return b.Lat, b.Lng
+	}
+	if δ == 0 {
+		return a.Lat, a.Lng
+	}
+
+	φ1, λ1 := a.Lat*math.Pi/180, a.Lng*math.Pi/180
+	φ2, λ2 := b.Lat*math.Pi/180, b.Lng*math.Pi/180
+
+	wa := math.Sin((1-f)*δ) / math.Sin(δ)
+	wb := math.Sin(f*δ) / math.Sin(δ)
+	x := wa*math.Cos(φ1)*math.Cos(λ1) + wb*math.Cos(φ2)*math.Cos(λ2)
+	y := wa*math.Cos(φ1)*math.Sin(λ1) + wb*math.Cos(φ2)*math.Sin(λ2)
+	z := wa*math.Sin(φ1) + wb*math.Sin(φ2)
+
+	return math.Atan2(z, math.Hypot(x, y)) * 180 / math.Pi, math.Atan2(y, x) * 180 / math.Pi
+}
@@ -0,0 +1,196 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var (
+	tokyoStation = Location{Name: "Tokyo", Lat: 35.6812, Lng: 139.7671}
+	shinOsaka    = Location{Name: "Shin-Osaka", Lat: 34.7334, Lng: 135.5001}
+	kyotoStation = Location{Name: "Kyoto", Lat: 34.9858, Lng: 135.7588}
+)
+
+func TestGreatCircleRouting(t *testing.T) {
+	res, err := GreatCircleRouting{}.Route(context.Background(), "train", []Location{tokyoStation, shinOsaka})
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+
+	wantKm := haversineKm(tokyoStation.Lat, tokyoStation.Lng, shinOsaka.Lat, shinOsaka.Lng)
+	if math.Abs(res.Distance-wantKm*1000) > 1 {
+		t.Errorf("distance = %.0f m, want %.0f m", res.Distance, wantKm*1000)
+	}
+	if want := estimateDuration("train", wantKm); res.Duration != want {
+		t.Errorf("duration = %.0f s, want %.0f s", res.Duration, want)
+	}
+
+	steps := int(math.Ceil(wantKm / greatCircleStepKm))
+	if len(res.Geometry) != steps+1 {
+		t.Fatalf("geometry has %d points, want %d", len(res.Geometry), steps+1)
+	}
+	first, last := res.Geometry[0], res.Geometry[len(res.Geometry)-1]
+	if first[0] != tokyoStation.Lng || first[1] != tokyoStation.Lat || last[0] != shinOsaka.Lng || last[1] != shinOsaka.Lat {
+		t.Errorf("geometry runs %v to %v, want the waypoints", first, last)
+	}
+
+	// Points are evenly spaced along the arc, so the segments add up to the
+	// great-circle distance and none is longer than a step
+	sumKm := 0.0
+	for i := 1; i < len(res.Geometry); i++ {
+		a, b := res.Geometry[i-1], res.Geometry[i]
+		segmentKm := haversineKm(a[1], a[0], b[1], b[0])
+		if segmentKm > greatCircleStepKm {
+			t.Errorf("segment %d is %.2f km, longer than %.0f km", i, segmentKm, greatCircleStepKm)
+		}
+		sumKm += segmentKm
+	}
+	if math.Abs(sumKm-wantKm) > 0.01 {
+		t.Errorf("segments add up to %.3f km, want %.3f km", sumKm, wantKm)
+	}
+}
+
+func TestGreatCircleRoutingMultipleLegs(t *testing.T) {
+	res, err := GreatCircleRouting{}.Route(context.Background(), "car", []Location{tokyoStation, kyotoStation, shinOsaka})
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+
+	leg1 := haversineKm(tokyoStation.Lat, tokyoStation.Lng, kyotoStation.Lat, kyotoStation.Lng)
+	leg2 := haversineKm(kyotoStation.Lat, kyotoStation.Lng, shinOsaka.Lat, shinOsaka.Lng)
+	if math.Abs(res.Distance-(leg1+leg2)*1000) > 1 {
+		t.Errorf("distance = %.0f m, want %.0f m", res.Distance, (leg1+leg2)*1000)
+	}
+	wantPoints := 1 + int(math.Ceil(leg1/greatCircleStepKm)) + int(math.Ceil(leg2/greatCircleStepKm))
+	if len(res.Geometry) != wantPoints {
+		t.Errorf("geometry has %d points, want %d", len(res.Geometry), wantPoints)
+	}
+}
+
+func TestGreatCircleRoutingSamePoint(t *testing.T) {
+	res, err := GreatCircleRouting{}.Route(context.Background(), "walk", []Location{tokyoStation, tokyoStation})
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+	if res.Distance != 0 || res.Duration != 0 {
+		t.Errorf("distance, duration = %v, %v; want 0", res.Distance, res.Duration)
+	}
+	if len(res.Geometry) != 2 {
+		t.Fatalf("geometry has %d points, want 2", len(res.Geometry))
+	}
+	for _, p := range res.Geometry {
+		if p[0] != tokyoStation.Lng || p[1] != tokyoStation.Lat {
+			t.Errorf("point %v, want the waypoint", p)
+		}
+	}
+}
+
+func TestGreatCirclePoint(t *testing.T) {
+	δ := haversineKm(tokyoStation.Lat, tokyoStation.Lng, shinOsaka.Lat, shinOsaka.Lng) / earthRadiusKm
+
+	if lat, lng := greatCirclePoint(tokyoStation, shinOsaka, δ, 1); lat != shinOsaka.Lat || lng != shinOsaka.Lng {
+		t.Errorf("f=1 gives %v,%v; want the destination exactly", lat, lng)
+	}
+	if lat, lng := greatCirclePoint(tokyoStation, tokyoStation, 0, 0.5); lat != tokyoStation.Lat || lng != tokyoStation.Lng {
+		t.Errorf("δ=0 gives %v,%v; want the origin", lat, lng)
+	}
+
+	lat, lng := greatCirclePoint(tokyoStation, shinOsaka, δ, 0.5)
+	fromOrigin := haversineKm(tokyoStation.Lat, tokyoStation.Lng, lat, lng)
+	toDestination := haversineKm(lat, lng, shinOsaka.Lat, shinOsaka.Lng)
+	if math.Abs(fromOrigin-toDestination) > 0.001 {
+		t.Errorf("midpoint is %.3f km from the origin and %.3f km from the destination", fromOrigin, toDestination)
+	}
+}
+
+func TestRoutingNeedsTwoWaypoints(t *testing.T) {
+	providers := []RoutingProvider{
+		GreatCircleRouting{},
+		NewOSRMRouting("http://127.0.0.1:1"),
+		&MapboxRouting{},
+	}
+	for _, p := range providers {
+		for _, waypoints := range [][]Location{nil, {tokyoStation}} {
+			if _, err := p.Route(context.Background(), "train", waypoints); !errors.Is(err, errTooFewWaypoints) {
+				t.Errorf("%s with %d waypoints: err = %v, want errTooFewWaypoints", p.Name(), len(waypoints), err)
+			}
+		}
+	}
+}
+
+func TestEstimateDuration(t *testing.T) {
+	if got := estimateDuration("walk", 5); got != 3600 {
+		t.Errorf("5 km walk = %v s, want 3600", got)
+	}
+	if got, want := estimateDuration("hovercraft", 50), 50/defaultAverageSpeedKmh*3600; got != want {
+		t.Errorf("unknown mode = %v s, want %v at the default speed", got, want)
+	}
+}
+
+// fakeOSRM serves one canned response and records the request path
+func fakeOSRM(t *testing.T, status int, body string, path *string) *OSRMRouting {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*path = r.URL.Path
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return NewOSRMRouting(srv.URL + "/")
+}
+
+func TestOSRMRouting(t *testing.T) {
+	var path string
+	p := fakeOSRM(t, http.StatusOK, `{
+		"code": "Ok",
+		"routes": [{
+			"geometry": {"type": "LineString", "coordinates": [[139.7671, 35.6812], [137.0, 35.1], [135.5001, 34.7334]]},
+			"distance": 515400.2,
+			"duration": 22100.7
+		}]
+	}`, &path)
+
+	res, err := p.Route(context.Background(), "walk", []Location{tokyoStation, shinOsaka})
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+	if want := "/route/v1/foot/139.7671,35.6812;135.5001,34.7334"; path != want {
+		t.Errorf("path = %q, want %q", path, want)
+	}
+	if res.Distance != 515400.2 || res.Duration != 22100.7 || len(res.Geometry) != 3 || res.Geometry[1][0] != 137 {
+		t.Errorf("result = %+v", res)
+	}
+
+	if _, err := p.Route(context.Background(), "train", []Location{tokyoStation, shinOsaka}); err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+	if !strings.HasPrefix(path, "/route/v1/driving/") {
+		t.Errorf("train path = %q, want the driving profile", path)
+	}
+}
+
+func TestOSRMRoutingNoRoute(t *testing.T) {
+	var path string
+	p := fakeOSRM(t, http.StatusBadRequest, `{"code":"NoRoute","message":"Impossible route between points"}`, &path)
+
+	_, err := p.Route(context.Background(), "car", []Location{tokyoStation, shinOsaka})
+	if err == nil || !strings.Contains(err.Error(), "NoRoute") || !strings.Contains(err.Error(), "Impossible route") {
+		t.Errorf("err = %v, want the NoRoute code and message", err)
+	}
+}
+
+func TestOSRMRoutingServerError(t *testing.T) {
+	var path string
+	p := fakeOSRM(t, http.StatusBadGateway, `<html>Bad Gateway</html>`, &path)
+
+	_, err := p.Route(context.Background(), "car", []Location{tokyoStation, shinOsaka})
+	if err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("err = %v, want one naming the 502 status", err)
+	}
+}
@@ -1,17 +1,19 @@
 package internal
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"math"
 	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
-	"sync"
-	"time"
+	"strings"
 
+	"github.com/hunterjsb/tokygo/internal/mapbox"
 	"github.com/uber/h3-go/v4"
 )
 
@@ -26,12 +28,231 @@ func okJSON(w http.ResponseWriter, v any) {
 	writeJSON(w, http.StatusOK, v)
 }
 
+// problemContentType is the RFC 7807 media type of error responses
+const problemContentType = "application/problem+json"
+
+// writeError sends an APIError as an RFC 7807 problem details document. Errors
+// are identified by Code rather than by a problem type URI, so Type is
+// "about:blank" and Title is the HTTP status text.
+func writeError(w http.ResponseWriter, status int, apiErr APIError) {
+	apiErr.Type = "about:blank"
+	apiErr.Title = http.StatusText(status)
+	apiErr.Status = status
+	apiErr.Detail = apiErr.Message
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(apiErr)
+}
+
+// writeInternalError sends a 500 for an unexpected failure
+func writeInternalError(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusInternalServerError, APIError{
+		Code:    ErrCodeInternal,
+		Message: message,
+	})
+}
+
+// responseFormat is an encoding a feature endpoint can respond with
+type responseFormat string
+
+const (
+	formatJSON       responseFormat = "json"       // the endpoint's native JSON shape
+	formatGeoJSON    responseFormat = "geojson"    // RFC 7946 FeatureCollection
+	formatGeoJSONSeq responseFormat = "geojsonseq" // RFC 8142 text sequence, one Feature per record
+	formatCSV        responseFormat = "csv"        // one row per feature with a WKT geometry column
+)
+
+// formatMediaTypes maps each format to the media type it is served as
+var formatMediaTypes = map[responseFormat]string{
+	formatJSON:       "application/json",
+	formatGeoJSON:    "application/geo+json",
+	formatGeoJSONSeq: "application/geo+json-seq",
+	formatCSV:        "text/csv",
+}
+
+// negotiateFormat picks the response format from the ?format= parameter or, failing
+// that, the Accept header. native is the endpoint's own shape, used for application/json,
+// wildcards and requests that express no preference. On failure it writes a 400 or 406
+// response and returns false.
+func negotiateFormat(w http.ResponseWriter, r *http.Request, native responseFormat) (responseFormat, bool) {
+	w.Header().Add("Vary", "Accept")
+
+	if param := r.URL.Query().Get("format"); param != "" {
+		format := responseFormat(strings.ToLower(param))
+		if _, ok := formatMediaTypes[format]; !ok {
+			writeError(w, http.StatusBadRequest, APIError{
+				Code:    ErrCodeUnsupportedFormat,
+				Field:   "format",
+				Message: fmt.Sprintf("unsupported format %q", param),
+				Details: []responseFormat{formatJSON, formatGeoJSON, formatGeoJSONSeq, formatCSV},
+			})
+			return "", false
+		}
+		if format == formatJSON {
+			format = native
+		}
+		return format, true
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return native, true
+	}
+
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mr := mediaRange{mediaType: strings.ToLower(strings.TrimSpace(fields[0])), q: 1}
+		for _, param := range fields[1:] {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					mr.q = q
+				}
+			}
+		}
+		if mr.q > 0 {
+			ranges = append(ranges, mr)
+		}
+	}
+	slices.SortStableFunc(ranges, func(a, b mediaRange) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+
+	for _, mr := range ranges {
+		switch mr.mediaType {
+		case "application/geo+json":
+			return formatGeoJSON, true
+		case "application/geo+json-seq":
+			return formatGeoJSONSeq, true
+		case "text/csv":
+			return formatCSV, true
+		case "application/json", "application/*", "*/*":
+			return native, true
+		}
+	}
+
+	writeError(w, http.StatusNotAcceptable, APIError{
+		Code:    ErrCodeNotAcceptable,
+		Message: fmt.Sprintf("cannot produce any of %q", accept),
+		Details: []string{"application/json", "application/geo+json", "application/geo+json-seq", "text/csv"},
+	})
+	return "", false
+}
+
+// writeFeatures encodes a feature collection in the given format
+func writeFeatures(w http.ResponseWriter, format responseFormat, fc *GeoJSON) {
+	switch format {
+	case formatGeoJSONSeq:
+		writeGeoJSONSeq(w, fc)
+	case formatCSV:
+		writeFeaturesCSV(w, fc)
+	default:
+		w.Header().Set("Content-Type", formatMediaTypes[formatGeoJSON])
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(fc)
+	}
+}
+
+// geoJSONSeqFlushEvery is how many records are written between flushes when streaming
+const geoJSONSeqFlushEvery = 500
+
+// writeGeoJSONSeq streams each feature as an RFC 8142 record (RS, JSON text, LF),
+// flushing periodically so clients can start rendering large grids early
+func writeGeoJSONSeq(w http.ResponseWriter, fc *GeoJSON) {
+	w.Header().Set("Content-Type", formatMediaTypes[formatGeoJSONSeq])
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w) // Encode terminates each record with LF
+	for i, feature := range fc.Features {
+		if _, err := w.Write([]byte{0x1e}); err != nil {
+			return
+		}
+		if err := encoder.Encode(feature); err != nil {
+			return
+		}
+		if flusher != nil && (i+1)%geoJSONSeqFlushEvery == 0 {
+			flusher.Flush()
+		}
+	}
+}
+
+// writeFeaturesCSV writes one row per feature: a WKT geometry column followed by
+// one column per property key (sorted), with list values joined by spaces
+func writeFeaturesCSV(w http.ResponseWriter, fc *GeoJSON) {
+	keySet := make(map[string]bool)
+	for _, feature := range fc.Features {
+		for key := range feature.Properties {
+			keySet[key] = true
+		}
+	}
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	w.Header().Set("Content-Type", formatMediaTypes[formatCSV]+"; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write(append([]string{"wkt"}, keys...))
+	for _, feature := range fc.Features {
+		row := make([]string, 0, len(keys)+1)
+		row = append(row, feature.Geometry.WKT())
+		for _, key := range keys {
+			row = append(row, csvValue(feature.Properties[key]))
+		}
+		if err := cw.Write(row); err != nil {
+			return
+		}
+	}
+	cw.Flush()
+}
+
+// csvValue formats a property value for a CSV cell
+func csvValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, " ")
+	case []float64:
+		parts := make([]string, len(v))
+		for i, f := range v {
+			parts[i] = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		return strings.Join(parts, " ")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case fmt.Stringer:
+		return v.String()
+	}
+	if b, err := json.Marshal(v); err == nil {
+		return strings.Trim(string(b), `"`)
+	}
+	return fmt.Sprint(v)
+}
+
 // corsMiddleware adds CORS headers to responses
 func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Accept")
 
 		// Handle preflight requests
 		if r.Method == "OPTIONS" {
@@ -43,47 +264,72 @@ func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// Config holds server configuration
-type Config struct {
-	Resolution int
-	RadiusKm   float64
-}
-
 // Server handles HTTP requests
 type Server struct {
-	RootDir      string
-	Config       Config
-	geojsonCache *GeoJSON
-	cacheMutex   sync.RWMutex
-	cacheTime    time.Time
+	RootDir   string
+	Config    Config
+	Store     TripStore
+	gridCache *GridCache
+
+	mapbox        *mapbox.Client
+	mapboxCache   *MapboxCache
+	mapboxLimiter *RateLimiter
 }
 
 // NewServer creates a new server instance
-func NewServer(rootDir string) *Server {
+func NewServer(rootDir string, cfg Config, store TripStore) *Server {
 	return &Server{
-		RootDir: rootDir,
-		Config: Config{
-			Resolution: 7,
-			RadiusKm:   15.0,
-		},
+		RootDir:   rootDir,
+		Config:    cfg,
+		Store:     store,
+		gridCache: NewGridCache(cfg.GridCacheMaxCells, cfg.GridCacheTTL),
+
+		mapbox:        mapbox.New(os.Getenv("MAPBOX_TOKEN")),
+		mapboxCache:   NewMapboxCache(cfg.MapboxCacheMaxEntries, cfg.MapboxCacheTTL, cfg.MapboxCacheDir, cfg.MapboxCacheDirMaxFiles),
+		mapboxLimiter: NewRateLimiter(cfg.MapboxRateLimit, cfg.MapboxRateBurst),
 	}
 }
 
 // RegisterHandlers sets up all HTTP routes
 func (s *Server) RegisterHandlers() {
+	// Health checks (used by scripts/health_check.sh during deploys)
+	http.HandleFunc("/health", s.handleHealth)
+	http.HandleFunc("/ready", s.handleReady)
+
 	// API endpoints with CORS support
-	http.HandleFunc("/api/cities", corsMiddleware(s.handleCities))
 	http.HandleFunc("/api/config", corsMiddleware(s.handleConfig))
-	http.HandleFunc("/api/routes", corsMiddleware(s.handleRoutes))
+	http.HandleFunc("/api/trips", corsMiddleware(s.handleTripsCollection))
+	http.HandleFunc("/api/trips/{trip}", corsMiddleware(s.handleTripItem))
+
+	// Trip-scoped endpoints; the unscoped /api/... forms serve DefaultTrip
+	for _, prefix := range []string{"/api", "/api/trips/{trip}"} {
+		http.HandleFunc(prefix+"/cities", corsMiddleware(s.handleCitiesCollection))
+		http.HandleFunc(prefix+"/routes", corsMiddleware(s.handleRoutes))
+		http.HandleFunc(prefix+"/routes/lines", corsMiddleware(s.handleRoutesLines))
+		http.HandleFunc(prefix+"/locations", corsMiddleware(s.handleLocationsCollection))
+		http.HandleFunc(prefix+"/locations/{id}", corsMiddleware(s.handleLocationItem))
+		http.HandleFunc(prefix+"/itinerary", corsMiddleware(s.handleItinerary))
+		http.HandleFunc(prefix+"/export/ics", corsMiddleware(s.handleExportICS))
+		http.HandleFunc(prefix+"/export/gpx", corsMiddleware(s.handleExportGPX))
+		http.HandleFunc(prefix+"/export/kml", corsMiddleware(s.handleExportKML))
+	}
 
-	http.HandleFunc("/api/routes/lines", corsMiddleware(s.handleRoutesLines))
-	http.HandleFunc("/api/locations", corsMiddleware(s.handleLocations))
 	http.HandleFunc("/api/h3/cell", corsMiddleware(s.handleH3Cell))
+	http.HandleFunc("/api/h3/cell/{index}", corsMiddleware(s.handleH3CellDetails))
 	http.HandleFunc("/api/h3/ring", corsMiddleware(s.handleH3Ring))
 	http.HandleFunc("/api/h3/grid", corsMiddleware(s.handleH3Grid))
 	http.HandleFunc("/api/h3/grid_window", corsMiddleware(s.handleH3GridWindow))
-	http.HandleFunc("/api/mapbox/directions", corsMiddleware(s.handleMapboxDirections))
-	http.HandleFunc("/api/mapbox/geocoding", corsMiddleware(s.handleMapboxGeocoding))
+	http.HandleFunc("/api/h3/grid/cache", corsMiddleware(s.handleH3GridCache))
+	http.HandleFunc("/api/h3/uncompact", corsMiddleware(s.handleH3Uncompact))
+	http.HandleFunc("/api/h3/neighbors", corsMiddleware(s.handleH3Neighbors))
+	http.HandleFunc("/tiles/h3/{z}/{x}/{y}", corsMiddleware(s.handleH3Tile))
+	http.HandleFunc("/tiles/trip/{z}/{x}/{y}", corsMiddleware(s.handleTripTile))
+	http.HandleFunc("/tiles/trips/{trip}/{z}/{x}/{y}", corsMiddleware(s.handleTripTile))
+	http.HandleFunc("/api/mapbox/directions", corsMiddleware(s.mapboxLimiter.Limit(s.handleMapboxDirections)))
+	http.HandleFunc("/api/mapbox/geocoding", corsMiddleware(s.mapboxLimiter.Limit(s.handleMapboxGeocoding)))
+
+	// Unknown API paths get a problem document rather than the static file server's 404
+	http.HandleFunc("/api/", corsMiddleware(s.handleAPINotFound))
 
 	// Custom handler for static files that doesn't catch /api routes
 	frontendDir := filepath.Join(s.RootDir, "frontend", "dist")
@@ -93,11 +339,32 @@ func (s *Server) RegisterHandlers() {
 	})
 }
 
-// handleCities returns the cities data as JSON
-func (s *Server) handleCities(w http.ResponseWriter, r *http.Request) {
+// handleAPINotFound answers requests for API paths no handler matches
+func (s *Server) handleAPINotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, APIError{
+		Code:    ErrCodeNotFound,
+		Message: fmt.Sprintf("no API endpoint at %s", r.URL.Path),
+	})
+}
+
+// handleCities returns the trip's cities data as JSON
+func (s *Server) handleCities(w http.ResponseWriter, r *http.Request, trip Trip) {
+	cities, err := s.Store.Cities(r.Context(), trip.ID)
+	if err != nil {
+		writeInternalError(w, fmt.Sprintf("Error loading cities: %v", err))
+		return
+	}
+
+	colors := make(map[string]string, len(cities))
+	for _, city := range cities {
+		if city.Color != "" {
+			colors[city.Name] = city.Color
+		}
+	}
+
 	response := CitiesResponse{
-		Cities: Cities,
-		Colors: CityColors,
+		Cities: cities,
+		Colors: colors,
 	}
 
 	okJSON(w, response)
@@ -105,28 +372,71 @@ func (s *Server) handleCities(w http.ResponseWriter, r *http.Request) {
 
 // handleConfig returns the server configuration
 func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	cfg := s.Config
 	response := ConfigResponse{
-		Resolution: s.Config.Resolution,
-		RadiusKm:   s.Config.RadiusKm,
+		Resolution: cfg.Resolution,
+		RadiusKm:   cfg.RadiusKm,
+
+		GridRegion:         cfg.GridRegion.BBox,
+		GridRegionPolygons: len(cfg.GridRegion.Polygons),
+		GridMaxCells:       cfg.GridMaxCells,
+		GridWindowMaxCells: cfg.GridWindowMaxCells,
+		GridWindowPageSize: cfg.GridWindowPageSize,
+		GridCacheMaxCells:  cfg.GridCacheMaxCells,
+		GridCacheTTL:       cfg.GridCacheTTL.Seconds(),
+
+		MapboxCacheMaxEntries:  cfg.MapboxCacheMaxEntries,
+		MapboxCacheTTL:         cfg.MapboxCacheTTL.Seconds(),
+		MapboxCacheOnDisk:      cfg.MapboxCacheDir != "",
+		MapboxCacheDirMaxFiles: cfg.MapboxCacheDirMaxFiles,
+		MapboxRateLimit:        cfg.MapboxRateLimit,
+		MapboxRateBurst:        cfg.MapboxRateBurst,
 	}
 
 	okJSON(w, response)
 }
 
-// handleRoutes returns the list of routes
+// handleRoutes returns the trip's list of routes
 func (s *Server) handleRoutes(w http.ResponseWriter, r *http.Request) {
+	trip, ok := s.tripFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	routes, err := s.Store.Routes(r.Context(), trip.ID)
+	if err != nil {
+		writeInternalError(w, fmt.Sprintf("Error loading routes: %v", err))
+		return
+	}
+
 	response := RoutesResponse{
-		Routes: TripRoutes,
+		Routes: routes,
 	}
 
 	okJSON(w, response)
 }
 
-// handleRoutesLines returns route lines as GeoJSON LineStrings
+// handleRoutesLines returns the trip's route lines as GeoJSON LineStrings
 func (s *Server) handleRoutesLines(w http.ResponseWriter, r *http.Request) {
+	trip, ok := s.tripFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	format, ok := negotiateFormat(w, r, formatGeoJSON)
+	if !ok {
+		return
+	}
+
+	routes, err := s.Store.Routes(r.Context(), trip.ID)
+	if err != nil {
+		writeInternalError(w, fmt.Sprintf("Error loading routes: %v", err))
+		return
+	}
+
 	features := []Feature{}
 
-	for _, route := range CachedRoutes {
+	for _, route := range CachedRoutesFor(routes) {
 		feature := Feature{
 			Type: "Feature",
 			Geometry: Geometry{
@@ -148,70 +458,59 @@ func (s *Server) handleRoutesLines(w http.ResponseWriter, r *http.Request) {
 		Features: features,
 	}
 
-	okJSON(w, geojson)
+	writeFeatures(w, format, geojson)
 }
 
-// handleLocations returns trip locations as GeoJSON points
-func (s *Server) handleLocations(w http.ResponseWriter, r *http.Request) {
-	geojson, err := GetLocationsGeoJSON(s.Config.Resolution)
+// handleLocations returns the trip's locations as GeoJSON points
+func (s *Server) handleLocations(w http.ResponseWriter, r *http.Request, trip Trip) {
+	format, ok := negotiateFormat(w, r, formatGeoJSON)
+	if !ok {
+		return
+	}
+
+	locations, err := s.Store.Locations(r.Context(), trip.ID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error generating locations GeoJSON: %v", err), http.StatusInternalServerError)
+		writeInternalError(w, fmt.Sprintf("Error loading locations: %v", err))
 		return
 	}
 
-	okJSON(w, geojson)
+	geojson, err := GetLocationsGeoJSON(locations, s.Config.Resolution)
+	if err != nil {
+		writeInternalError(w, fmt.Sprintf("Error generating locations GeoJSON: %v", err))
+		return
+	}
+
+	writeFeatures(w, format, geojson)
 }
 
 // handleH3Cell returns H3 cell and boundary for a given lat/lng
 func (s *Server) handleH3Cell(w http.ResponseWriter, r *http.Request) {
-	latStr := r.URL.Query().Get("lat")
-	lngStr := r.URL.Query().Get("lng")
-	resStr := r.URL.Query().Get("resolution")
-
-	if latStr == "" || lngStr == "" {
-		http.Error(w, "lat and lng parameters required", http.StatusBadRequest)
+	lat, ok := queryLat(w, r, "lat")
+	if !ok {
 		return
 	}
-
-	lat, err := strconv.ParseFloat(latStr, 64)
-	if err != nil {
-		http.Error(w, "invalid lat", http.StatusBadRequest)
+	lng, ok := queryLng(w, r, "lng")
+	if !ok {
 		return
 	}
-
-	lng, err := strconv.ParseFloat(lngStr, 64)
-	if err != nil {
-		http.Error(w, "invalid lng", http.StatusBadRequest)
+	resolution, ok := queryResolution(w, r, 9)
+	if !ok {
 		return
 	}
 
-	resolution := 9
-	if resStr != "" {
-		res, err := strconv.Atoi(resStr)
-		if err == nil {
-			resolution = res
-		}
-	}
-
 	latLng := h3.LatLng{Lat: lat, Lng: lng}
 	cell, err := h3.LatLngToCell(latLng, resolution)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error getting H3 cell: %v", err), http.StatusInternalServerError)
+		writeInternalError(w, fmt.Sprintf("Error getting H3 cell: %v", err))
 		return
 	}
 
-	boundary, err := cell.Boundary()
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error getting boundary: %v", err), http.StatusInternalServerError)
+	coords, ok := cellBoundary(cell)
+	if !ok {
+		writeInternalError(w, "Error getting boundary")
 		return
 	}
 
-	coords := make([][]float64, len(boundary)+1)
-	for i, latLng := range boundary {
-		coords[i] = []float64{latLng.Lng, latLng.Lat}
-	}
-	coords[len(boundary)] = []float64{boundary[0].Lng, boundary[0].Lat}
-
 	response := H3CellResponse{
 		H3Index:  cell.String(),
 		Boundary: coords,
@@ -220,303 +519,779 @@ func (s *Server) handleH3Cell(w http.ResponseWriter, r *http.Request) {
 	okJSON(w, response)
 }
 
-// handleH3Ring returns the ring of 6 neighboring cells
-func (s *Server) handleH3Ring(w http.ResponseWriter, r *http.Request) {
-	h3IndexStr := r.URL.Query().Get("h3_index")
-	if h3IndexStr == "" {
-		http.Error(w, "h3_index parameter required", http.StatusBadRequest)
+// h3CellMaxChildren is the most children /api/h3/cell/{index} lists individually
+const h3CellMaxChildren = 343
+
+// handleH3CellDetails returns the hierarchy and metrics of one cell:
+// /api/h3/cell/{index}?children=N reports descendants at resolution N
+// (default the next finer resolution), listing them when there are at most
+// h3CellMaxChildren.
+func (s *Server) handleH3CellDetails(w http.ResponseWriter, r *http.Request) {
+	cell, ok := parseCell(w, "index", r.PathValue("index"))
+	if !ok {
 		return
 	}
+	resolution := cell.Resolution()
 
-	cell := h3.Cell(0)
-	if err := cell.UnmarshalText([]byte(h3IndexStr)); err != nil {
-		http.Error(w, "invalid h3_index", http.StatusBadRequest)
-		return
+	// The next finer resolution, or none at all for the finest cells
+	childRes := resolution + 1
+	if resolution < h3.MaxResolution {
+		if childRes, ok = queryInt(w, r, "children", childRes, resolution+1, h3.MaxResolution); !ok {
+			return
+		}
 	}
 
-	// Get the ring of neighbors (k=1 means immediate neighbors)
-	ring, err := cell.GridDisk(1)
+	center, err := cell.LatLng()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error getting ring: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, APIError{Code: ErrCodeInternal, Message: err.Error()})
 		return
 	}
-
-	ringData := []H3RingCell{}
-	for _, ringCell := range ring {
-		if ringCell == cell {
-			continue // Skip the center cell
+	boundary, _ := cellBoundary(cell)
+	faces, _ := cell.IcosahedronFaces()
+	areaKm2, _ := h3.CellAreaKm2(cell)
+	areaM2, _ := h3.CellAreaM2(cell)
+
+	edgeKm, edgeM := 0.0, 0.0
+	if edges, err := cell.DirectedEdges(); err == nil {
+		count := 0
+		for _, edge := range edges {
+			km, errKm := h3.EdgeLengthKm(edge)
+			m, errM := h3.EdgeLengthM(edge)
+			if errKm != nil || errM != nil {
+				continue
+			}
+			edgeKm += km
+			edgeM += m
+			count++
+		}
+		if count > 0 {
+			edgeKm /= float64(count)
+			edgeM /= float64(count)
 		}
+	}
 
-		boundary, err := ringCell.Boundary()
+	parents := make([]H3CellParent, 0, resolution)
+	for res := 0; res < resolution; res++ {
+		parent, err := cell.Parent(res)
 		if err != nil {
 			continue
 		}
+		parents = append(parents, H3CellParent{Resolution: res, H3Index: parent.String()})
+	}
 
-		coords := make([][]float64, len(boundary)+1)
-		for i, latLng := range boundary {
-			coords[i] = []float64{latLng.Lng, latLng.Lat}
-		}
-		coords[len(boundary)] = []float64{boundary[0].Lng, boundary[0].Lat}
-
-		ringData = append(ringData, H3RingCell{
-			H3Index:  ringCell.String(),
-			Boundary: coords,
-		})
+	response := H3CellDetailsResponse{
+		H3Index:          cell.String(),
+		Resolution:       resolution,
+		BaseCell:         cell.BaseCellNumber(),
+		IsPentagon:       cell.IsPentagon(),
+		IsResClassIII:    cell.IsResClassIII(),
+		IcosahedronFaces: faces,
+		Center:           []float64{center.Lng, center.Lat},
+		Boundary:         boundary,
+		AreaKm2:          areaKm2,
+		AreaM2:           areaM2,
+		EdgeLengthKm:     edgeKm,
+		EdgeLengthM:      edgeM,
+		Parents:          parents,
 	}
 
-	response := H3RingResponse{
-		Ring: ringData,
+	if childRes <= h3.MaxResolution {
+		children := &H3CellChildren{
+			Resolution: childRes,
+			Count:      childCount(cell, childRes),
+		}
+		if children.Count <= h3CellMaxChildren {
+			cells, err := cell.Children(childRes)
+			if err == nil {
+				for _, child := range cells {
+					children.Cells = append(children.Cells, child.String())
+				}
+			}
+		}
+		response.Children = children
 	}
 
 	okJSON(w, response)
 }
 
-// handleH3Grid returns a precomputed grid of H3 cells for the Japan region
-func (s *Server) handleH3Grid(w http.ResponseWriter, r *http.Request) {
-	resStr := r.URL.Query().Get("resolution")
-	resolution := 7
-	if resStr != "" {
-		res, err := strconv.Atoi(resStr)
-		if err == nil {
-			resolution = res
-		}
+// childCount is the number of descendants of cell at resolution: 7 per level
+// for hexagons; a pentagon's center child is a pentagon with only 5 other children
+func childCount(cell h3.Cell, resolution int) int {
+	levels := resolution - cell.Resolution()
+	hexagons := int(math.Pow(7, float64(levels)))
+	if cell.IsPentagon() {
+		return 1 + 5*(hexagons-1)/6
 	}
+	return hexagons
+}
 
-	// Define bounding box for Japan region
-	minLat, maxLat := 30.0, 46.0
-	minLng, maxLng := 128.0, 146.0
+// h3RingMaxK bounds /api/h3/ring; a disk of radius k holds 3k(k+1)+1 cells
+const h3RingMaxK = 50
+
+// handleH3Ring returns cells around an origin cell. Query params:
+// - h3_index: origin cell (required)
+// - k: grid distance (optional, default 1, at most h3RingMaxK)
+// - mode: ring (default), disk or distances
+//
+// ring returns only the cells exactly k away (k=1 is the 6 neighbors), disk all
+// cells within k including the origin, and distances the disk nearest first with
+// each cell's grid distance from the origin.
+func (s *Server) handleH3Ring(w http.ResponseWriter, r *http.Request) {
+	cell, ok := queryCell(w, r, "h3_index")
+	if !ok {
+		return
+	}
+	k, ok := queryInt(w, r, "k", 1, 0, h3RingMaxK)
+	if !ok {
+		return
+	}
 
-	cells := make(map[string]H3CellInfo)
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "ring"
+	}
 
-	// Generate grid
-	for lat := minLat; lat <= maxLat; lat += 0.3 {
-		for lng := minLng; lng <= maxLng; lng += 0.3 {
-			latLng := h3.LatLng{Lat: lat, Lng: lng}
-			cell, err := h3.LatLngToCell(latLng, resolution)
-			if err != nil {
-				continue
-			}
+	// Cells grouped by distance from the origin; only distances mode reports them
+	var rings [][]h3.Cell
+	var err error
+	switch mode {
+	case "ring":
+		var ring []h3.Cell
+		ring, err = cell.GridRing(k)
+		rings = [][]h3.Cell{ring}
+	case "disk":
+		var disk []h3.Cell
+		disk, err = cell.GridDisk(k)
+		rings = [][]h3.Cell{disk}
+	case "distances":
+		rings, err = cell.GridDiskDistances(k)
+	default:
+		writeError(w, http.StatusBadRequest, APIError{
+			Code:    ErrCodeInvalidValue,
+			Field:   "mode",
+			Message: fmt.Sprintf("unknown mode %q", mode),
+			Details: []string{"ring", "disk", "distances"},
+		})
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, APIError{
+			Code:    ErrCodeInternal,
+			Message: fmt.Sprintf("Error getting ring: %v", err),
+		})
+		return
+	}
 
-			h3Index := cell.String()
-			if _, exists := cells[h3Index]; exists {
+	ringData := []H3RingCell{}
+	for distance, ring := range rings {
+		for _, ringCell := range ring {
+			// Cells crossing a pentagon can come back as zero
+			if ringCell == 0 {
 				continue
 			}
 
-			boundary, err := cell.Boundary()
-			if err != nil {
+			boundary, ok := cellBoundary(ringCell)
+			if !ok {
 				continue
 			}
 
-			coords := make([][]float64, len(boundary)+1)
-			for i, ll := range boundary {
-				coords[i] = []float64{ll.Lng, ll.Lat}
+			ringCellData := H3RingCell{
+				H3Index:  ringCell.String(),
+				Boundary: boundary,
 			}
-			coords[len(boundary)] = []float64{boundary[0].Lng, boundary[0].Lat}
-
-			center, _ := cell.LatLng()
-
-			// Get neighbors
-			neighbors, _ := cell.GridDisk(1)
-			neighborIndices := []string{}
-			for _, n := range neighbors {
-				if n != cell {
-					neighborIndices = append(neighborIndices, n.String())
-				}
-			}
-
-			cells[h3Index] = H3CellInfo{
-				Boundary:  coords,
-				Center:    []float64{center.Lng, center.Lat},
-				Neighbors: neighborIndices,
+			if mode == "distances" {
+				ringCellData.Distance = &distance
 			}
+			ringData = append(ringData, ringCellData)
 		}
 	}
 
-	response := H3GridResponse{
-		Cells:      cells,
-		Resolution: resolution,
+	response := H3RingResponse{
+		Ring: ringData,
+		K:    k,
+		Mode: mode,
 	}
 
 	okJSON(w, response)
 }
 
-// handleH3GridWindow returns H3 cells within a provided bounding box at a given resolution
-// Query params:
-// - minLat, minLng, maxLat, maxLng: bounding box (required)
-// - resolution: H3 resolution (optional, default 7)
-func (s *Server) handleH3GridWindow(w http.ResponseWriter, r *http.Request) {
-	minLatStr := r.URL.Query().Get("minLat")
-	minLngStr := r.URL.Query().Get("minLng")
-	maxLatStr := r.URL.Query().Get("maxLat")
-	maxLngStr := r.URL.Query().Get("maxLng")
-	resStr := r.URL.Query().Get("resolution")
-
-	if minLatStr == "" || minLngStr == "" || maxLatStr == "" || maxLngStr == "" {
-		http.Error(w, "minLat, minLng, maxLat, and maxLng parameters are required", http.StatusBadRequest)
+// defaultGridResolution is the finest /api/h3/grid resolution served when none
+// is requested
+const defaultGridResolution = 7
+
+// handleH3Grid returns the H3 cells covering the configured grid region (the
+// Japan bbox unless GRID_REGION names a bbox or GeoJSON land polygon).
+// Without ?resolution=, the grid is the finest resolution up to
+// defaultGridResolution whose estimate fits GridMaxCells; the response reports
+// which. An explicit resolution whose grid would exceed GridMaxCells gets a 422
+// whose details suggest the finest one that fits.
+// With ?compact=true, complete groups of sibling cells are merged into their parents;
+// ?fields=boundary,center,neighbors limits what each cell includes.
+// Supports ?format=geojson|geojsonseq|csv (or Accept) for polygon features.
+func (s *Server) handleH3Grid(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiateFormat(w, r, formatJSON)
+	if !ok {
+		return
+	}
+	compact, ok := queryBool(w, r, "compact")
+	if !ok {
+		return
+	}
+	fields, ok := parseCellFields(w, r, format)
+	if !ok {
 		return
 	}
 
-	minLat, err := strconv.ParseFloat(minLatStr, 64)
-	if err != nil {
-		http.Error(w, "invalid minLat", http.StatusBadRequest)
+	region := s.Config.GridRegion
+	maxCells := s.Config.GridMaxCells
+
+	resolution, ok := queryResolution(w, r, -1)
+	if !ok {
 		return
 	}
-	minLng, err := strconv.ParseFloat(minLngStr, 64)
-	if err != nil {
-		http.Error(w, "invalid minLng", http.StatusBadRequest)
+	if resolution < 0 {
+		// If even resolution 0 is too large, the check below rejects it
+		resolution = max(0, finestGridResolution(region, defaultGridResolution, maxCells))
+	}
+
+	if estimate := region.EstimateCells(resolution); estimate > maxCells {
+		writeGridLimitError(w, resolution, estimate, maxCells, finestGridResolution(region, resolution, maxCells))
 		return
 	}
-	maxLat, err := strconv.ParseFloat(maxLatStr, 64)
-	if err != nil {
-		http.Error(w, "invalid maxLat", http.StatusBadRequest)
+
+	key := gridKey{Resolution: resolution, BBox: region.BBox, Region: region.Source, Compact: compact}
+	cells, hit, err := s.gridCells(key, func() (map[string]H3CellInfo, error) {
+		return cappedPolyfill(region.Polygons, resolution, maxCells)
+	})
+	var limitErr *gridLimitError
+	if errors.As(err, &limitErr) {
+		writeGridLimitError(w, resolution, limitErr.cells, maxCells, finestGridResolution(region, resolution-1, maxCells))
 		return
 	}
-	maxLng, err := strconv.ParseFloat(maxLngStr, 64)
 	if err != nil {
-		http.Error(w, "invalid maxLng", http.StatusBadRequest)
+		writeError(w, http.StatusInternalServerError, APIError{
+			Code:    ErrCodeInternal,
+			Message: fmt.Sprintf("Error generating H3 cells for grid region: %v", err),
+		})
 		return
 	}
+	setCacheStatus(w, hit)
+	cells = projectCells(cells, fields)
 
-	if minLat > maxLat || minLng > maxLng {
-		http.Error(w, "min values must be <= max values", http.StatusBadRequest)
+	if format != formatJSON {
+		writeFeatures(w, format, H3CellsGeoJSON(cells))
 		return
 	}
 
-	resolution := 7
-	if resStr != "" {
-		if res, err := strconv.Atoi(resStr); err == nil {
-			resolution = res
+	response := H3GridResponse{
+		Cells:      cells,
+		Resolution: resolution,
+		Compact:    compact,
+	}
+
+	okJSON(w, response)
+}
+
+// gridLimitError reports a polyfilled grid with more cells than allowed. The
+// estimate checked beforehand can fall short for odd shapes, so the actual
+// count is checked too; failing the fill keeps the oversized grid out of the cache.
+type gridLimitError struct {
+	cells int
+}
+
+func (e *gridLimitError) Error() string {
+	return fmt.Sprintf("grid has %d cells, more than allowed", e.cells)
+}
+
+// cappedPolyfill polyfills polygons at resolution, returning a *gridLimitError
+// if the grid has more than maxCells cells
+func cappedPolyfill(polygons []h3.GeoPolygon, resolution, maxCells int) (map[string]H3CellInfo, error) {
+	cells, err := polyfillGrid(polygons, resolution)
+	if err != nil {
+		return nil, err
+	}
+	if len(cells) > maxCells {
+		return nil, &gridLimitError{cells: len(cells)}
+	}
+	return cells, nil
+}
+
+// finestGridResolution returns the finest resolution no finer than limit whose
+// estimated cell count over region fits within maxCells, or -1 if none does
+func finestGridResolution(region GridRegion, limit, maxCells int) int {
+	for res := limit; res >= 0; res-- {
+		if region.EstimateCells(res) <= maxCells {
+			return res
 		}
 	}
+	return -1
+}
 
-	cells := make(map[string]H3CellInfo)
+// writeGridLimitError rejects a grid request that would return more than maxCells cells
+func writeGridLimitError(w http.ResponseWriter, resolution, cells, maxCells, suggested int) {
+	message := fmt.Sprintf("resolution %d would return about %d cells, more than the limit of %d", resolution, cells, maxCells)
+	if suggested >= 0 {
+		message += fmt.Sprintf("; use resolution %d or coarser", suggested)
+	}
+	writeError(w, http.StatusUnprocessableEntity, APIError{
+		Code:    ErrCodeOutOfRange,
+		Field:   "resolution",
+		Message: message,
+		Details: GridLimitDetails{
+			EstimatedCells:      cells,
+			MaxCells:            maxCells,
+			SuggestedResolution: suggested,
+		},
+	})
+}
 
-	// Build a polygon for the current viewport bbox and densely cover it with H3 cells
-	loop := h3.GeoLoop{
-		{Lat: minLat, Lng: minLng},
-		{Lat: minLat, Lng: maxLng},
-		{Lat: maxLat, Lng: maxLng},
-		{Lat: maxLat, Lng: minLng},
+// cellInfo builds the boundary, center and neighbors of a cell
+func cellInfo(cell h3.Cell) (H3CellInfo, bool) {
+	coords, ok := cellBoundary(cell)
+	if !ok {
+		return H3CellInfo{}, false
 	}
-	polygon := h3.GeoPolygon{GeoLoop: loop}
 
-	polyCells, err := h3.PolygonToCells(polygon, resolution)
+	center, _ := cell.LatLng()
+
+	return H3CellInfo{
+		Boundary:  coords,
+		Center:    []float64{center.Lng, center.Lat},
+		Neighbors: cellNeighbors(cell),
+	}, true
+}
+
+// cellBoundary returns a cell's boundary as a closed [lng, lat] ring
+func cellBoundary(cell h3.Cell) (H3Boundary, bool) {
+	boundary, err := cell.Boundary()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error generating H3 cells for bbox: %v", err), http.StatusInternalServerError)
-		return
+		return nil, false
 	}
 
-	for _, cell := range polyCells {
-		h3Index := cell.String()
-		if _, exists := cells[h3Index]; exists {
-			continue
+	coords := make([][]float64, len(boundary)+1)
+	for i, ll := range boundary {
+		coords[i] = []float64{ll.Lng, ll.Lat}
+	}
+	coords[len(boundary)] = []float64{boundary[0].Lng, boundary[0].Lat}
+	return coords, true
+}
+
+// cellNeighbors lists the indexes of the cells adjacent to cell
+func cellNeighbors(cell h3.Cell) []string {
+	neighbors, _ := cell.GridDisk(1)
+	neighborIndices := []string{}
+	for _, n := range neighbors {
+		if n != cell {
+			neighborIndices = append(neighborIndices, n.String())
 		}
+	}
+	return neighborIndices
+}
 
-		boundary, err := cell.Boundary()
-		if err != nil {
-			continue
+// cellFields selects which H3CellInfo fields a grid response includes
+type cellFields struct {
+	Boundary  bool
+	Center    bool
+	Neighbors bool
+}
+
+// cellFieldNames are the values accepted by the fields= parameter
+var cellFieldNames = []string{"boundary", "center", "neighbors"}
+
+// parseCellFields reads the optional ?fields= list (default: all fields). Feature
+// formats need a geometry, so they require boundary or center.
+func parseCellFields(w http.ResponseWriter, r *http.Request, format responseFormat) (cellFields, bool) {
+	fieldsStr := r.URL.Query().Get("fields")
+	if fieldsStr == "" {
+		return cellFields{Boundary: true, Center: true, Neighbors: true}, true
+	}
+
+	var fields cellFields
+	for _, name := range strings.Split(fieldsStr, ",") {
+		switch strings.TrimSpace(name) {
+		case "boundary":
+			fields.Boundary = true
+		case "center":
+			fields.Center = true
+		case "neighbors":
+			fields.Neighbors = true
+		default:
+			writeError(w, http.StatusBadRequest, APIError{
+				Code:    ErrCodeInvalidValue,
+				Field:   "fields",
+				Message: fmt.Sprintf("unknown field %q", name),
+				Details: cellFieldNames,
+			})
+			return fields, false
 		}
+	}
+
+	if format != formatJSON && !fields.Boundary && !fields.Center {
+		writeParamError(w, ErrCodeInvalidValue, "fields", fmt.Sprintf("fields must include boundary or center for %s output", format))
+		return fields, false
+	}
+	return fields, true
+}
+
+// projectCells copies cells keeping only the selected fields; cached grids are
+// shared, so they are never modified in place
+func projectCells(cells map[string]H3CellInfo, fields cellFields) map[string]H3CellInfo {
+	if fields.Boundary && fields.Center && fields.Neighbors {
+		return cells
+	}
 
-		coords := make([][]float64, len(boundary)+1)
-		for i, ll := range boundary {
-			coords[i] = []float64{ll.Lng, ll.Lat}
+	projected := make(map[string]H3CellInfo, len(cells))
+	for h3Index, info := range cells {
+		var p H3CellInfo
+		if fields.Boundary {
+			p.Boundary = info.Boundary
 		}
-		coords[len(boundary)] = []float64{boundary[0].Lng, boundary[0].Lat}
+		if fields.Center {
+			p.Center = info.Center
+		}
+		if fields.Neighbors {
+			p.Neighbors = info.Neighbors
+		}
+		projected[h3Index] = p
+	}
+	return projected
+}
 
-		center, _ := cell.LatLng()
+// h3NeighborsMaxCells caps how many cells one /api/h3/neighbors request may ask about
+const h3NeighborsMaxCells = 10000
 
-		// Neighbors (optional; helpful for UI effects)
-		neighbors, _ := cell.GridDisk(1)
-		neighborIndices := []string{}
-		for _, n := range neighbors {
-			if n != cell {
-				neighborIndices = append(neighborIndices, n.String())
-			}
+// handleH3Neighbors returns the neighbors of many cells at once, so grid clients
+// can request fields=boundary and look neighbors up only when needed (e.g. on hover).
+// POST {"cells": ["872f5a32cffffff", ...]}
+func (s *Server) handleH3Neighbors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, "POST, OPTIONS")
+		return
+	}
+
+	var req H3NeighborsRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if len(req.Cells) == 0 {
+		writeParamError(w, ErrCodeRequired, "cells", "at least one cell is required")
+		return
+	}
+	if len(req.Cells) > h3NeighborsMaxCells {
+		writeParamError(w, ErrCodeOutOfRange, "cells", fmt.Sprintf("at most %d cells per request, got %d", h3NeighborsMaxCells, len(req.Cells)))
+		return
+	}
+
+	cells, ok := parseCells(w, "cells", req.Cells)
+	if !ok {
+		return
+	}
+
+	neighbors := make(map[string][]string, len(cells))
+	for _, cell := range cells {
+		neighbors[cell.String()] = cellNeighbors(cell)
+	}
+
+	okJSON(w, H3NeighborsResponse{Neighbors: neighbors})
+}
+
+// gridCells returns the grid for key from the cache, calling fill to compute the
+// full grid on a miss. A compact key is derived from the (also cached) full grid.
+func (s *Server) gridCells(key gridKey, fill func() (map[string]H3CellInfo, error)) (map[string]H3CellInfo, bool, error) {
+	if !key.Compact {
+		return s.gridCache.Get(key, fill)
+	}
+
+	return s.gridCache.Get(key, func() (map[string]H3CellInfo, error) {
+		fullKey := key
+		fullKey.Compact = false
+		full, _, err := s.gridCache.Get(fullKey, fill)
+		if err != nil {
+			return nil, err
 		}
+		return compactGrid(full)
+	})
+}
+
+// compactGrid replaces every complete set of sibling cells with their parent,
+// recursively, yielding a mixed-resolution grid covering the same area
+func compactGrid(cells map[string]H3CellInfo) (map[string]H3CellInfo, error) {
+	if len(cells) == 0 {
+		return cells, nil
+	}
+
+	in := make([]h3.Cell, 0, len(cells))
+	for h3Index := range cells {
+		in = append(in, h3.Cell(h3.IndexFromString(h3Index)))
+	}
+	compacted, err := h3.CompactCells(in)
+	if err != nil {
+		return nil, err
+	}
 
-		cells[h3Index] = H3CellInfo{
-			Boundary:  coords,
-			Center:    []float64{center.Lng, center.Lat},
-			Neighbors: neighborIndices,
+	out := make(map[string]H3CellInfo, len(compacted))
+	for _, cell := range compacted {
+		h3Index := cell.String()
+		if info, ok := cells[h3Index]; ok {
+			out[h3Index] = info
+		} else if info, ok := cellInfo(cell); ok {
+			out[h3Index] = info
 		}
 	}
+	return out, nil
+}
 
-	response := H3GridWindowResponse{
-		Cells:      cells,
-		Resolution: resolution,
-		BBox: BBox{
-			MinLat: minLat,
-			MinLng: minLng,
-			MaxLat: maxLat,
-			MaxLng: maxLng,
-		},
+// setCacheStatus reports whether a response was served from a cache
+func setCacheStatus(w http.ResponseWriter, hit bool) {
+	if hit {
+		w.Header().Set("X-Cache", "HIT")
+	} else {
+		w.Header().Set("X-Cache", "MISS")
 	}
+}
 
-	okJSON(w, response)
+// handleH3GridCache returns grid cache occupancy and hit/miss counters
+func (s *Server) handleH3GridCache(w http.ResponseWriter, r *http.Request) {
+	okJSON(w, s.gridCache.Stats())
 }
 
-// handleMapboxDirections proxies requests to Mapbox Directions API
-func (s *Server) handleMapboxDirections(w http.ResponseWriter, r *http.Request) {
-	token := os.Getenv("MAPBOX_TOKEN")
-	if token == "" {
-		http.Error(w, "Mapbox token not configured", http.StatusInternalServerError)
+// handleH3GridWindow returns H3 cells within a provided bounding box at a given resolution
+// Query params:
+// - minLat, minLng, maxLat, maxLng: bounding box (required)
+// - resolution: H3 resolution (optional, default 7)
+// - limit: cells per page (optional, default and maximum GridWindowPageSize)
+// - cursor: nextCursor from the previous page (optional)
+// - compact: true to merge complete sibling sets into parent cells (optional)
+// - fields: comma-separated subset of boundary,center,neighbors (optional, default all)
+// - format: json (default), geojson, geojsonseq or csv
+//
+// Windows estimated to exceed GridWindowMaxCells are rejected with a 422 that
+// suggests a coarser resolution. Pages are ordered by H3 index; a Link header
+// with rel="next" points at the following page in every format.
+func (s *Server) handleH3GridWindow(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiateFormat(w, r, formatJSON)
+	if !ok {
 		return
 	}
 
-	// Get query parameters (URL decoded automatically by Query().Get())
-	coordinates := r.URL.Query().Get("coordinates")
-	if coordinates == "" {
-		http.Error(w, "coordinates parameter required", http.StatusBadRequest)
+	bbox, ok := queryBBox(w, r)
+	if !ok {
+		return
+	}
+	resolution, ok := queryResolution(w, r, 7)
+	if !ok {
+		return
+	}
+	compact, ok := queryBool(w, r, "compact")
+	if !ok {
+		return
+	}
+	fields, ok := parseCellFields(w, r, format)
+	if !ok {
+		return
+	}
+	limit, ok := queryInt(w, r, "limit", s.Config.GridWindowPageSize, 1, s.Config.GridWindowPageSize)
+	if !ok {
 		return
 	}
 
-	// Build Mapbox API URL (coordinates already decoded by Query().Get())
-	mapboxURL := fmt.Sprintf("https://api.mapbox.com/directions/v5/mapbox/driving/%s?access_token=%s&geometries=geojson&overview=full",
-		coordinates, token)
+	cursor := r.URL.Query().Get("cursor")
+	if cursor != "" {
+		cell, ok := parseCell(w, "cursor", cursor)
+		if !ok {
+			return
+		}
+		if cell.Resolution() != resolution && (!compact || cell.Resolution() > resolution) {
+			writeParamError(w, ErrCodeInvalidValue, "cursor", "cursor must be the nextCursor of a previous page at the same resolution")
+			return
+		}
+		cursor = cell.String()
+	}
+
+	maxCells := s.Config.GridWindowMaxCells
+	region := BBoxRegion(bbox)
+	if estimate := region.EstimateCells(resolution); estimate > maxCells {
+		writeGridLimitError(w, resolution, estimate, maxCells, finestGridResolution(region, resolution, maxCells))
+		return
+	}
 
-	// Make request to Mapbox
-	resp, err := http.Get(mapboxURL)
+	cells, hit, err := s.gridCells(gridKey{Resolution: resolution, BBox: bbox, Compact: compact}, func() (map[string]H3CellInfo, error) {
+		return cappedPolyfill(region.Polygons, resolution, maxCells)
+	})
+	var limitErr *gridLimitError
+	if errors.As(err, &limitErr) {
+		writeGridLimitError(w, resolution, limitErr.cells, maxCells, finestGridResolution(region, resolution-1, maxCells))
+		return
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error calling Mapbox API: %v", err), http.StatusInternalServerError)
+		writeInternalError(w, fmt.Sprintf("Error generating H3 cells for bbox: %v", err))
 		return
 	}
-	defer resp.Body.Close()
+	setCacheStatus(w, hit)
+
+	page, nextCursor := gridPage(cells, cursor, limit)
+	page = projectCells(page, fields)
+	if nextCursor != "" {
+		next := *r.URL
+		query := next.Query()
+		query.Set("cursor", nextCursor)
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+	}
 
-	// Copy response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
-	io.Copy(w, resp.Body)
+	if format != formatJSON {
+		writeFeatures(w, format, H3CellsGeoJSON(page))
+		return
+	}
+
+	response := H3GridWindowResponse{
+		Cells:      page,
+		Resolution: resolution,
+		BBox:       bbox,
+		TotalCells: len(cells),
+		NextCursor: nextCursor,
+		Compact:    compact,
+	}
+
+	okJSON(w, response)
 }
 
-// handleMapboxGeocoding proxies requests to Mapbox Geocoding API
-func (s *Server) handleMapboxGeocoding(w http.ResponseWriter, r *http.Request) {
-	token := os.Getenv("MAPBOX_TOKEN")
-	if token == "" {
-		http.Error(w, "Mapbox token not configured", http.StatusInternalServerError)
+// handleH3Uncompact expands compacted cells back to a single resolution: the
+// reverse of compact=true. Takes ?cells=a,b,c&resolution=N, or the same as a
+// JSON body ({"cells": [...], "resolution": N}) via POST for long cell lists.
+// Expansions larger than GridWindowMaxCells are rejected with a 422.
+func (s *Server) handleH3Uncompact(w http.ResponseWriter, r *http.Request) {
+	var req H3UncompactRequest
+	switch r.Method {
+	case http.MethodGet:
+		if cellsStr := r.URL.Query().Get("cells"); cellsStr != "" {
+			req.Cells = strings.Split(cellsStr, ",")
+		}
+		if r.URL.Query().Get("resolution") == "" {
+			writeParamError(w, ErrCodeRequired, "resolution", "resolution parameter required")
+			return
+		}
+		res, ok := queryResolution(w, r, 0)
+		if !ok {
+			return
+		}
+		req.Resolution = res
+	case http.MethodPost:
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+	default:
+		methodNotAllowed(w, "GET, POST, OPTIONS")
 		return
 	}
 
-	// Get query parameters
-	query := r.URL.Query().Get("q")
-	if query == "" {
-		http.Error(w, "q parameter required", http.StatusBadRequest)
+	if len(req.Cells) == 0 {
+		writeParamError(w, ErrCodeRequired, "cells", "at least one cell is required")
+		return
+	}
+	if req.Resolution < 0 || req.Resolution > h3.MaxResolution {
+		writeParamError(w, ErrCodeOutOfRange, "resolution", fmt.Sprintf("resolution must be between 0 and %d", h3.MaxResolution))
 		return
 	}
 
-	// Build Mapbox API URL
-	mapboxURL := fmt.Sprintf("https://api.mapbox.com/geocoding/v5/mapbox.places/%s.json?access_token=%s&country=JP",
-		url.QueryEscape(query), token)
+	cells, ok := parseCells(w, "cells", req.Cells)
+	if !ok {
+		return
+	}
+	finest := 0
+	for _, cell := range cells {
+		if cell.Resolution() > req.Resolution {
+			writeParamError(w, ErrCodeOutOfRange, "resolution",
+				fmt.Sprintf("resolution %d is coarser than cell %s at resolution %d", req.Resolution, cell, cell.Resolution()))
+			return
+		}
+		finest = max(finest, cell.Resolution())
+	}
+
+	maxCells := s.Config.GridWindowMaxCells
+	if count := uncompactedCount(cells, req.Resolution); count > maxCells {
+		suggested := -1
+		for res := req.Resolution - 1; res >= finest; res-- {
+			if uncompactedCount(cells, res) <= maxCells {
+				suggested = res
+				break
+			}
+		}
+		writeGridLimitError(w, req.Resolution, count, maxCells, suggested)
+		return
+	}
 
-	// Make request to Mapbox
-	resp, err := http.Get(mapboxURL)
+	expanded, err := h3.UncompactCells(cells, req.Resolution)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error calling Mapbox API: %v", err), http.StatusInternalServerError)
+		writeParamError(w, ErrCodeInvalidValue, "cells", fmt.Sprintf("cannot uncompact cells: %v", err))
 		return
 	}
-	defer resp.Body.Close()
 
-	// Copy response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
-	io.Copy(w, resp.Body)
+	indexes := make([]string, len(expanded))
+	for i, cell := range expanded {
+		indexes[i] = cell.String()
+	}
+	slices.Sort(indexes)
+	indexes = slices.Compact(indexes)
+
+	okJSON(w, H3UncompactResponse{
+		Cells:      indexes,
+		Resolution: req.Resolution,
+	})
+}
+
+// uncompactedCount is how many cells at resolution the given cells expand to
+// (an upper bound: pentagons have one fewer child per level)
+func uncompactedCount(cells []h3.Cell, resolution int) int {
+	total := 0.0
+	for _, cell := range cells {
+		total += math.Pow(7, float64(resolution-cell.Resolution()))
+	}
+	return int(math.Min(total, math.MaxInt32))
+}
+
+// gridPage returns up to limit cells that sort after cursor by H3 index, and
+// the cursor for the following page ("" on the last page)
+func gridPage(cells map[string]H3CellInfo, cursor string, limit int) (map[string]H3CellInfo, string) {
+	indexes := make([]string, 0, len(cells))
+	for h3Index := range cells {
+		if h3Index > cursor {
+			indexes = append(indexes, h3Index)
+		}
+	}
+	slices.Sort(indexes)
+
+	nextCursor := ""
+	if len(indexes) > limit {
+		indexes = indexes[:limit]
+		nextCursor = indexes[limit-1]
+	}
+
+	page := make(map[string]H3CellInfo, len(indexes))
+	for _, h3Index := range indexes {
+		page[h3Index] = cells[h3Index]
+	}
+	return page, nextCursor
+}
+
+// polyfillGrid covers polygons with the H3 cells whose centers they contain
+func polyfillGrid(polygons []h3.GeoPolygon, resolution int) (map[string]H3CellInfo, error) {
+	cells := make(map[string]H3CellInfo)
+	for _, polygon := range polygons {
+		polyCells, err := h3.PolygonToCells(polygon, resolution)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cell := range polyCells {
+			h3Index := cell.String()
+			if _, exists := cells[h3Index]; exists {
+				continue
+			}
+			if info, ok := cellInfo(cell); ok {
+				cells[h3Index] = info
+			}
+		}
+	}
+	return cells, nil
 }
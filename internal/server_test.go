@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestServer returns a server on an in-memory store
+func newTestServer(t *testing.T, cfg Config) *Server {
+	t.Helper()
+	return NewServer(t.TempDir(), cfg, NewMemoryStore())
+}
+
+func TestH3GridDefaultResolutionFits(t *testing.T) {
+	s := newTestServer(t, DefaultConfig())
+
+	w := httptest.NewRecorder()
+	s.handleH3Grid(w, httptest.NewRequest(http.MethodGet, "/api/h3/grid?fields=center", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp H3GridResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	want := finestGridResolution(s.Config.GridRegion, defaultGridResolution, s.Config.GridMaxCells)
+	if resp.Resolution != want {
+		t.Errorf("resolution = %d, want %d, the finest that fits", resp.Resolution, want)
+	}
+	if n := len(resp.Cells); n == 0 || n > s.Config.GridMaxCells {
+		t.Errorf("grid has %d cells, want 1 to %d", n, s.Config.GridMaxCells)
+	}
+}
+
+func TestH3GridExplicitResolutionOverLimit(t *testing.T) {
+	s := newTestServer(t, DefaultConfig())
+
+	w := httptest.NewRecorder()
+	s.handleH3Grid(w, httptest.NewRequest(http.MethodGet, "/api/h3/grid?resolution=7", nil))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", w.Code)
+	}
+	var problem struct {
+		Details GridLimitDetails `json:"details"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if want := finestGridResolution(s.Config.GridRegion, 6, s.Config.GridMaxCells); problem.Details.SuggestedResolution != want {
+		t.Errorf("suggested resolution = %d, want %d", problem.Details.SuggestedResolution, want)
+	}
+}
@@ -0,0 +1,608 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations are applied in order; PRAGMA user_version records how many have run
+var sqliteMigrations = []string{
+	`CREATE TABLE cities (
+		name     TEXT PRIMARY KEY,
+		lat      REAL NOT NULL,
+		lng      REAL NOT NULL,
+		color    TEXT NOT NULL DEFAULT '',
+		position INTEGER NOT NULL
+	);
+	CREATE TABLE locations (
+		id   INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		type TEXT NOT NULL,
+		city TEXT NOT NULL REFERENCES cities(name),
+		lat  REAL NOT NULL,
+		lng  REAL NOT NULL
+	);
+	CREATE TABLE routes (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		name        TEXT NOT NULL,
+		type        TEXT NOT NULL,
+		origin      TEXT NOT NULL,
+		destination TEXT NOT NULL,
+		waypoints   TEXT NOT NULL DEFAULT '[]',
+		distance    REAL NOT NULL,
+		duration    INTEGER NOT NULL
+	);`,
+
+	// Multi-trip support: existing rows are assigned to the default trip
+	`CREATE TABLE trips (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		name       TEXT NOT NULL,
+		start_date TEXT NOT NULL,
+		end_date   TEXT NOT NULL,
+		timezone   TEXT NOT NULL
+	);
+	INSERT INTO trips (id, name, start_date, end_date, timezone)
+		SELECT 1, 'Japan', '2025-11-03', '2025-11-10', 'Asia/Tokyo' WHERE EXISTS (SELECT 1 FROM cities);
+
+	CREATE TABLE cities_v2 (
+		trip_id  INTEGER NOT NULL REFERENCES trips(id) ON DELETE CASCADE,
+		name     TEXT NOT NULL,
+		lat      REAL NOT NULL,
+		lng      REAL NOT NULL,
+		color    TEXT NOT NULL DEFAULT '',
+		position INTEGER NOT NULL,
+		PRIMARY KEY (trip_id, name)
+	);
+	INSERT INTO cities_v2 (trip_id, name, lat, lng, color, position)
+		SELECT 1, name, lat, lng, color, position FROM cities;
+	DROP TABLE cities;
+	ALTER TABLE cities_v2 RENAME TO cities;
+
+	CREATE TABLE locations_v2 (
+		id      INTEGER PRIMARY KEY AUTOINCREMENT,
+		trip_id INTEGER NOT NULL REFERENCES trips(id) ON DELETE CASCADE,
+		name    TEXT NOT NULL,
+		type    TEXT NOT NULL,
+		city    TEXT NOT NULL,
+		lat     REAL NOT NULL,
+		lng     REAL NOT NULL,
+		FOREIGN KEY (trip_id, city) REFERENCES cities(trip_id, name)
+	);
+	INSERT INTO locations_v2 (id, trip_id, name, type, city, lat, lng)
+		SELECT id, 1, name, type, city, lat, lng FROM locations;
+	DROP TABLE locations;
+	ALTER TABLE locations_v2 RENAME TO locations;
+
+	CREATE TABLE routes_v2 (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		trip_id     INTEGER NOT NULL REFERENCES trips(id) ON DELETE CASCADE,
+		name        TEXT NOT NULL,
+		type        TEXT NOT NULL,
+		origin      TEXT NOT NULL,
+		destination TEXT NOT NULL,
+		waypoints   TEXT NOT NULL DEFAULT '[]',
+		distance    REAL NOT NULL,
+		duration    INTEGER NOT NULL
+	);
+	INSERT INTO routes_v2 (id, trip_id, name, type, origin, destination, waypoints, distance, duration)
+		SELECT id, 1, name, type, origin, destination, waypoints, distance, duration FROM routes;
+	DROP TABLE routes;
+	ALTER TABLE routes_v2 RENAME TO routes;`,
+
+	// Itinerary schedule; location and route references are checked by
+	// ValidateSchedule, and DeleteLocation refuses to remove referenced locations
+	`CREATE TABLE schedule (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		trip_id     INTEGER NOT NULL REFERENCES trips(id) ON DELETE CASCADE,
+		kind        TEXT NOT NULL,
+		location_id INTEGER,
+		route_id    INTEGER,
+		start_time  TEXT NOT NULL,
+		end_time    TEXT NOT NULL
+	);
+	CREATE INDEX schedule_trip_start ON schedule (trip_id, start_time);`,
+}
+
+// scheduleMigration is the migration that created the schedule table; databases
+// migrated past it get the seed schedule for DefaultTrip
+const scheduleMigration = 3
+
+// SQLiteStore is a TripStore backed by a SQLite database file
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStore opens (or creates) the database at path, applies any pending
+// migrations and seeds a newly created database with the built-in trip data.
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("opening database %s: %w", path, err)
+	}
+	// SQLite allows a single writer; serialize access through one connection
+	db.SetMaxOpenConns(1)
+
+	store := &SQLiteStore{db: db}
+	fromVersion, err := store.migrate()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := store.seed(fromVersion); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// migrate applies the migrations that have not yet run against the database.
+// It returns the schema version the database had beforehand (0 when newly created).
+func (s *SQLiteStore) migrate() (int, error) {
+	ctx := context.Background()
+
+	// Pin one connection so foreign key enforcement can be switched off while
+	// migrations rebuild tables; the pragma is a no-op inside a transaction.
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var version int
+	if err := conn.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("reading schema version: %w", err)
+	}
+	if version >= len(sqliteMigrations) {
+		return version, nil
+	}
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return 0, err
+	}
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("applying migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("recording migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, err
+		}
+	}
+
+	// Migrations run with enforcement off, so check that they left the references intact
+	rows, err := conn.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		return 0, fmt.Errorf("migrations left foreign key violations")
+	}
+
+	return version, rows.Err()
+}
+
+// seed inserts the built-in data that is newer than fromVersion: a new database
+// gets DefaultTrip with its cities, locations and routes, and any database that
+// predates the schedule table gets TripSchedule if DefaultTrip still exists.
+func (s *SQLiteStore) seed(fromVersion int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if fromVersion == 0 {
+		if err := seedDefaultTrip(tx); err != nil {
+			return err
+		}
+	}
+
+	if fromVersion < scheduleMigration {
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM trips WHERE id = ?)`, DefaultTripID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			if err := insertSchedule(tx, DefaultTripID, TripSchedule); err != nil {
+				return fmt.Errorf("seeding schedule: %w", err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// seedDefaultTrip inserts DefaultTrip with its cities, locations and routes
+func seedDefaultTrip(tx *sql.Tx) error {
+	trip := DefaultTrip
+	_, err := tx.Exec(`INSERT INTO trips (id, name, start_date, end_date, timezone) VALUES (?, ?, ?, ?, ?)`,
+		trip.ID, trip.Name, trip.StartDate, trip.EndDate, trip.Timezone)
+	if err != nil {
+		return fmt.Errorf("seeding trip %s: %w", trip.Name, err)
+	}
+
+	for i, city := range seedCities() {
+		if err := insertCity(tx, city, i); err != nil {
+			return fmt.Errorf("seeding city %s: %w", city.Name, err)
+		}
+	}
+
+	for _, loc := range seedLocations() {
+		_, err := tx.Exec(`INSERT INTO locations (id, trip_id, name, type, city, lat, lng) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			loc.ID, loc.TripID, loc.Name, string(loc.Type), loc.City, loc.Lat, loc.Lng)
+		if err != nil {
+			return fmt.Errorf("seeding location %s: %w", loc.Name, err)
+		}
+	}
+
+	for _, route := range seedRoutes() {
+		if err := insertRoute(tx, route); err != nil {
+			return fmt.Errorf("seeding route %s: %w", route.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// insertCity writes a city row at the given display position
+func insertCity(db execer, city City, position int) error {
+	_, err := db.Exec(`INSERT INTO cities (trip_id, name, lat, lng, color, position) VALUES (?, ?, ?, ?, ?, ?)`,
+		city.TripID, city.Name, city.Lat, city.Lng, city.Color, position)
+	return err
+}
+
+// insertRoute writes a route row, encoding its Location fields as JSON
+func insertRoute(db execer, route Route) error {
+	origin, err := json.Marshal(route.Origin)
+	if err != nil {
+		return err
+	}
+	destination, err := json.Marshal(route.Destination)
+	if err != nil {
+		return err
+	}
+	waypoints, err := json.Marshal(route.Waypoints)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`INSERT INTO routes (id, trip_id, name, type, origin, destination, waypoints, distance, duration)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		route.ID, route.TripID, route.Name, route.Type, string(origin), string(destination), string(waypoints),
+		route.Distance, route.Duration)
+	return err
+}
+
+func (s *SQLiteStore) Trips(ctx context.Context) ([]Trip, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, start_date, end_date, timezone FROM trips ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trips := []Trip{}
+	for rows.Next() {
+		var trip Trip
+		if err := rows.Scan(&trip.ID, &trip.Name, &trip.StartDate, &trip.EndDate, &trip.Timezone); err != nil {
+			return nil, err
+		}
+		trips = append(trips, trip)
+	}
+	return trips, rows.Err()
+}
+
+func (s *SQLiteStore) Trip(ctx context.Context, id int64) (Trip, error) {
+	var trip Trip
+	err := s.db.QueryRowContext(ctx, `SELECT id, name, start_date, end_date, timezone FROM trips WHERE id = ?`, id).
+		Scan(&trip.ID, &trip.Name, &trip.StartDate, &trip.EndDate, &trip.Timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Trip{}, ErrNotFound
+	}
+	return trip, err
+}
+
+func (s *SQLiteStore) CreateTrip(ctx context.Context, trip Trip) (Trip, error) {
+	result, err := s.db.ExecContext(ctx, `INSERT INTO trips (name, start_date, end_date, timezone) VALUES (?, ?, ?, ?)`,
+		trip.Name, trip.StartDate, trip.EndDate, trip.Timezone)
+	if err != nil {
+		return Trip{}, err
+	}
+	trip.ID, err = result.LastInsertId()
+	if err != nil {
+		return Trip{}, err
+	}
+	return trip, nil
+}
+
+func (s *SQLiteStore) UpdateTrip(ctx context.Context, trip Trip) (Trip, error) {
+	result, err := s.db.ExecContext(ctx, `UPDATE trips SET name = ?, start_date = ?, end_date = ?, timezone = ? WHERE id = ?`,
+		trip.Name, trip.StartDate, trip.EndDate, trip.Timezone, trip.ID)
+	if err != nil {
+		return Trip{}, err
+	}
+	if err := requireRowAffected(result); err != nil {
+		return Trip{}, err
+	}
+	return trip, nil
+}
+
+func (s *SQLiteStore) DeleteTrip(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM trips WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
+
+func (s *SQLiteStore) Cities(ctx context.Context, tripID int64) ([]City, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT trip_id, name, lat, lng, color FROM cities
+		WHERE trip_id = ? ORDER BY position`, tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cities := []City{}
+	for rows.Next() {
+		var city City
+		if err := rows.Scan(&city.TripID, &city.Name, &city.Lat, &city.Lng, &city.Color); err != nil {
+			return nil, err
+		}
+		cities = append(cities, city)
+	}
+	return cities, rows.Err()
+}
+
+func (s *SQLiteStore) CreateCity(ctx context.Context, city City) (City, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return City{}, err
+	}
+	defer tx.Rollback()
+
+	var position int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM cities WHERE trip_id = ?`, city.TripID).Scan(&position); err != nil {
+		return City{}, err
+	}
+	if err := insertCity(tx, city, position); err != nil {
+		return City{}, err
+	}
+	return city, tx.Commit()
+}
+
+// locationColumns is the column list scanned by scanLocation
+const locationColumns = `id, trip_id, name, type, city, lat, lng`
+
+// scanLocation reads a locations row selected with locationColumns
+func scanLocation(row interface{ Scan(...any) error }) (TripLocation, error) {
+	var loc TripLocation
+	var locType string
+	err := row.Scan(&loc.ID, &loc.TripID, &loc.Name, &locType, &loc.City, &loc.Lat, &loc.Lng)
+	loc.Type = LocationType(locType)
+	return loc, err
+}
+
+func (s *SQLiteStore) Locations(ctx context.Context, tripID int64) ([]TripLocation, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+locationColumns+` FROM locations WHERE trip_id = ? ORDER BY id`, tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locations := []TripLocation{}
+	for rows.Next() {
+		loc, err := scanLocation(rows)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, loc)
+	}
+	return locations, rows.Err()
+}
+
+func (s *SQLiteStore) Location(ctx context.Context, tripID, id int64) (TripLocation, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+locationColumns+` FROM locations WHERE trip_id = ? AND id = ?`, tripID, id)
+	loc, err := scanLocation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TripLocation{}, ErrNotFound
+	}
+	return loc, err
+}
+
+func (s *SQLiteStore) CreateLocation(ctx context.Context, loc TripLocation) (TripLocation, error) {
+	result, err := s.db.ExecContext(ctx, `INSERT INTO locations (trip_id, name, type, city, lat, lng) VALUES (?, ?, ?, ?, ?, ?)`,
+		loc.TripID, loc.Name, string(loc.Type), loc.City, loc.Lat, loc.Lng)
+	if err != nil {
+		return TripLocation{}, err
+	}
+	loc.ID, err = result.LastInsertId()
+	if err != nil {
+		return TripLocation{}, err
+	}
+	return loc, nil
+}
+
+func (s *SQLiteStore) UpdateLocation(ctx context.Context, loc TripLocation) (TripLocation, error) {
+	result, err := s.db.ExecContext(ctx, `UPDATE locations SET name = ?, type = ?, city = ?, lat = ?, lng = ?
+		WHERE trip_id = ? AND id = ?`,
+		loc.Name, string(loc.Type), loc.City, loc.Lat, loc.Lng, loc.TripID, loc.ID)
+	if err != nil {
+		return TripLocation{}, err
+	}
+	if err := requireRowAffected(result); err != nil {
+		return TripLocation{}, err
+	}
+	return loc, nil
+}
+
+func (s *SQLiteStore) DeleteLocation(ctx context.Context, tripID, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT kind, location_id, route_id, start_time, end_time
+		FROM schedule WHERE trip_id = ? AND location_id = ? ORDER BY start_time, id`, tripID, id)
+	if err != nil {
+		return err
+	}
+	refs, err := scanSchedule(rows)
+	if err != nil {
+		return err
+	}
+	if len(refs) > 0 {
+		return &ReferencedError{Items: refs}
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM locations WHERE trip_id = ? AND id = ?`, tripID, id)
+	if err != nil {
+		return err
+	}
+	if err := requireRowAffected(result); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// requireRowAffected returns ErrNotFound when an UPDATE or DELETE matched no rows
+func requireRowAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Routes(ctx context.Context, tripID int64) ([]Route, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, trip_id, name, type, origin, destination, waypoints, distance, duration
+		FROM routes WHERE trip_id = ? ORDER BY id`, tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	routes := []Route{}
+	for rows.Next() {
+		var route Route
+		var origin, destination, waypoints string
+		if err := rows.Scan(&route.ID, &route.TripID, &route.Name, &route.Type, &origin, &destination, &waypoints,
+			&route.Distance, &route.Duration); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(origin), &route.Origin); err != nil {
+			return nil, fmt.Errorf("route %d origin: %w", route.ID, err)
+		}
+		if err := json.Unmarshal([]byte(destination), &route.Destination); err != nil {
+			return nil, fmt.Errorf("route %d destination: %w", route.ID, err)
+		}
+		if err := json.Unmarshal([]byte(waypoints), &route.Waypoints); err != nil {
+			return nil, fmt.Errorf("route %d waypoints: %w", route.ID, err)
+		}
+		routes = append(routes, route)
+	}
+	return routes, rows.Err()
+}
+
+// insertSchedule writes schedule rows for a trip, storing times as RFC 3339 text
+func insertSchedule(db execer, tripID int64, items []ScheduleItem) error {
+	for _, item := range items {
+		_, err := db.Exec(`INSERT INTO schedule (trip_id, kind, location_id, route_id, start_time, end_time)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			tripID, string(item.Kind), nullableID(item.LocationID), nullableID(item.RouteID),
+			item.Start.Format(time.RFC3339), item.End.Format(time.RFC3339))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nullableID stores a zero ID as NULL
+func nullableID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+func (s *SQLiteStore) Schedule(ctx context.Context, tripID int64) ([]ScheduleItem, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT kind, location_id, route_id, start_time, end_time
+		FROM schedule WHERE trip_id = ? ORDER BY start_time, id`, tripID)
+	if err != nil {
+		return nil, err
+	}
+	return scanSchedule(rows)
+}
+
+// scanSchedule reads and closes rows of kind, location_id, route_id,
+// start_time and end_time, returning the items in start order
+func scanSchedule(rows *sql.Rows) ([]ScheduleItem, error) {
+	defer rows.Close()
+
+	items := []ScheduleItem{}
+	for rows.Next() {
+		var item ScheduleItem
+		var kind, start, end string
+		var locationID, routeID sql.NullInt64
+		err := rows.Scan(&kind, &locationID, &routeID, &start, &end)
+		if err != nil {
+			return nil, err
+		}
+		item.Kind = ScheduleKind(kind)
+		item.LocationID = locationID.Int64
+		item.RouteID = routeID.Int64
+		if item.Start, err = time.Parse(time.RFC3339, start); err != nil {
+			return nil, fmt.Errorf("schedule start time: %w", err)
+		}
+		if item.End, err = time.Parse(time.RFC3339, end); err != nil {
+			return nil, fmt.Errorf("schedule end time: %w", err)
+		}
+		items = append(items, item)
+	}
+	// start_time is stored with its offset, so order by instant rather than text
+	return sortedSchedule(items), rows.Err()
+}
+
+func (s *SQLiteStore) ReplaceSchedule(ctx context.Context, tripID int64, items []ScheduleItem) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM schedule WHERE trip_id = ?`, tripID); err != nil {
+		return err
+	}
+	if err := insertSchedule(tx, tripID, items); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned by a TripStore when the requested record does not exist
+var ErrNotFound = errors.New("not found")
+
+// ReferencedError is returned by a TripStore when a record cannot be deleted
+// because the trip's itinerary still refers to it
+type ReferencedError struct {
+	Items []ScheduleItem // the itinerary entries referring to the record
+}
+
+func (e *ReferencedError) Error() string {
+	return fmt.Sprintf("still used by %d itinerary entries", len(e.Items))
+}
+
+// TripStore provides access to the trip data served by the API.
+// Cities, locations and routes are owned by a trip and always looked up by trip ID.
+// Implementations must be safe for concurrent use.
+type TripStore interface {
+	// Trips returns every trip ordered by ID
+	Trips(ctx context.Context) ([]Trip, error)
+	// Trip returns the trip with the given ID, or ErrNotFound
+	Trip(ctx context.Context, id int64) (Trip, error)
+	// CreateTrip stores a new trip and returns it with its assigned ID
+	CreateTrip(ctx context.Context, trip Trip) (Trip, error)
+	// UpdateTrip replaces the trip with trip.ID, or returns ErrNotFound
+	UpdateTrip(ctx context.Context, trip Trip) (Trip, error)
+	// DeleteTrip removes a trip along with everything it owns, or returns ErrNotFound
+	DeleteTrip(ctx context.Context, id int64) error
+
+	// Cities returns the trip's cities in display order
+	Cities(ctx context.Context, tripID int64) ([]City, error)
+	// CreateCity appends a city to city.TripID
+	CreateCity(ctx context.Context, city City) (City, error)
+
+	// Locations returns the trip's points of interest
+	Locations(ctx context.Context, tripID int64) ([]TripLocation, error)
+	// Location returns the trip's location with the given ID, or ErrNotFound
+	Location(ctx context.Context, tripID, id int64) (TripLocation, error)
+	// CreateLocation stores a new location for loc.TripID and returns it with its assigned ID
+	CreateLocation(ctx context.Context, loc TripLocation) (TripLocation, error)
+	// UpdateLocation replaces the location with loc.TripID and loc.ID, or returns ErrNotFound
+	UpdateLocation(ctx context.Context, loc TripLocation) (TripLocation, error)
+	// DeleteLocation removes the trip's location with the given ID, or returns
+	// ErrNotFound, or a *ReferencedError if the trip's schedule has stops there
+	DeleteLocation(ctx context.Context, tripID, id int64) error
+
+	// Routes returns the trip's travel routes
+	Routes(ctx context.Context, tripID int64) ([]Route, error)
+
+	// Schedule returns the trip's itinerary entries in start order
+	Schedule(ctx context.Context, tripID int64) ([]ScheduleItem, error)
+	// ReplaceSchedule replaces all of the trip's itinerary entries
+	ReplaceSchedule(ctx context.Context, tripID int64, items []ScheduleItem) error
+
+	// Close releases any resources held by the store
+	Close() error
+}
+
+// OpenStore opens the trip store for the given database path.
+// An empty path returns an in-memory store; anything else is a SQLite database file
+// that is created and seeded on first use.
+func OpenStore(path string) (TripStore, error) {
+	if path == "" {
+		return NewMemoryStore(), nil
+	}
+	return OpenSQLiteStore(path)
+}
+
+// seedCities returns a copy of Cities owned by DefaultTrip with their CityColors
+func seedCities() []City {
+	cities := make([]City, len(Cities))
+	for i, city := range Cities {
+		city.TripID = DefaultTripID
+		city.Color = CityColors[city.Name]
+		cities[i] = city
+	}
+	return cities
+}
+
+// seedLocations returns a copy of TripLocations owned by DefaultTrip with sequential IDs
+func seedLocations() []TripLocation {
+	locations := make([]TripLocation, len(TripLocations))
+	for i, loc := range TripLocations {
+		loc.ID = int64(i + 1)
+		loc.TripID = DefaultTripID
+		locations[i] = loc
+	}
+	return locations
+}
+
+// seedRoutes returns a copy of TripRoutes owned by DefaultTrip with sequential IDs
+func seedRoutes() []Route {
+	routes := make([]Route, len(TripRoutes))
+	for i, route := range TripRoutes {
+		route.ID = int64(i + 1)
+		route.TripID = DefaultTripID
+		route.Waypoints = append([]Location(nil), route.Waypoints...)
+		routes[i] = route
+	}
+	return routes
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// testStores returns a fresh seeded store of each implementation
+func testStores(t *testing.T) map[string]TripStore {
+	t.Helper()
+	sqlite, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "trip.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+	return map[string]TripStore{"memory": NewMemoryStore(), "sqlite": sqlite}
+}
+
+func TestDeleteLocationInItinerary(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			// The seed itinerary stays at location 1 (the Shinjuku hotel)
+			err := store.DeleteLocation(ctx, DefaultTripID, 1)
+			var refErr *ReferencedError
+			if !errors.As(err, &refErr) {
+				t.Fatalf("err = %v, want *ReferencedError", err)
+			}
+			if len(refErr.Items) != 1 || refErr.Items[0].LocationID != 1 || refErr.Items[0].Kind != ScheduleKindStop {
+				t.Errorf("referring items = %+v, want the one stop at location 1", refErr.Items)
+			}
+			if _, err := store.Location(ctx, DefaultTripID, 1); err != nil {
+				t.Errorf("location 1 after refused delete: %v", err)
+			}
+
+			// Unscheduled locations delete normally
+			loc, err := store.CreateLocation(ctx, TripLocation{TripID: DefaultTripID, Name: "Kiosk", Type: LocationTypeSight, City: "Tokyo", Lat: 35.68, Lng: 139.76})
+			if err != nil {
+				t.Fatalf("CreateLocation: %v", err)
+			}
+			if err := store.DeleteLocation(ctx, DefaultTripID, loc.ID); err != nil {
+				t.Errorf("deleting unscheduled location: %v", err)
+			}
+
+			// Once the itinerary no longer stops there, location 1 can go too
+			items, err := store.Schedule(ctx, DefaultTripID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var kept []ScheduleItem
+			for _, item := range items {
+				if item.LocationID != 1 {
+					kept = append(kept, item)
+				}
+			}
+			if err := store.ReplaceSchedule(ctx, DefaultTripID, kept); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.DeleteLocation(ctx, DefaultTripID, 1); err != nil {
+				t.Errorf("deleting location 1 after removing its stop: %v", err)
+			}
+			if err := store.DeleteLocation(ctx, DefaultTripID, 1); !errors.Is(err, ErrNotFound) {
+				t.Errorf("deleting location 1 again: err = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestWriteStoreErrorReferenced(t *testing.T) {
+	items := TripSchedule[2:3]
+	w := httptest.NewRecorder()
+	writeStoreError(w, &ReferencedError{Items: items}, "location", 1)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	var problem struct {
+		Code    string         `json:"code"`
+		Details []ScheduleItem `json:"details"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Code != ErrCodeConflict || len(problem.Details) != 1 || problem.Details[0].LocationID != 1 {
+		t.Errorf("problem = %+v, want a conflict listing the stop at location 1", problem)
+	}
+}
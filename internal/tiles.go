@@ -0,0 +1,245 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/hunterjsb/tokygo/internal/mvt"
+	"github.com/uber/h3-go/v4"
+)
+
+const (
+	// h3TileMinZoom is the lowest zoom that renders the H3 grid; coarser tiles
+	// span too much of the globe to polyfill meaningfully
+	h3TileMinZoom = 3
+	// h3TileMaxResolution caps the zoom-derived resolution
+	h3TileMaxResolution = 11
+	// h3TileMaxCells limits the cells encoded into one tile
+	h3TileMaxCells = 20000
+	// tilePixels is the rendered size of a vector tile in Mapbox GL
+	tilePixels = 512
+	// h3TileCellEdgePixels is the on-screen hexagon edge length the zoom mapping aims for
+	h3TileCellEdgePixels = 16
+	// tileBufferFraction expands the polyfill area so cells straddling the tile edge are included
+	tileBufferFraction = 0.125
+	// tileCacheControl lets browsers and CDNs cache tiles; their content is static
+	tileCacheControl = "public, max-age=86400"
+	// tripTileCacheControl is shorter since trip data can be edited through the API
+	tripTileCacheControl = "public, max-age=300"
+	// tripTileBuffer is how far (in tile units) route lines extend past the tile edge
+	// so stroked lines join seamlessly across tiles
+	tripTileBuffer = 64
+	// tripTileTolerance is the Douglas-Peucker tolerance in tile units (about one pixel)
+	tripTileTolerance = float64(mvt.DefaultExtent) / tilePixels
+)
+
+// mvtContentType is the media type of Mapbox Vector Tiles
+const mvtContentType = "application/vnd.mapbox-vector-tile"
+
+// h3ResolutionForZoom picks the H3 resolution whose average hexagon edge is closest
+// (on a log scale) to h3TileCellEdgePixels at the tile's zoom and latitude.
+func h3ResolutionForZoom(tile mvt.TileID) int {
+	_, minLat, _, maxLat := tile.Bounds()
+	lat := (minLat + maxLat) / 2
+	tileWidthKm := 2 * math.Pi * earthRadiusKm * math.Cos(lat*math.Pi/180) / math.Exp2(float64(tile.Z))
+	targetEdgeKm := tileWidthKm / tilePixels * h3TileCellEdgePixels
+
+	best, bestDiff := 0, math.Inf(1)
+	for res := 0; res <= h3TileMaxResolution; res++ {
+		edgeKm, err := h3.HexagonEdgeLengthAvgKm(res)
+		if err != nil {
+			continue
+		}
+		if diff := math.Abs(math.Log(edgeKm / targetEdgeKm)); diff < bestDiff {
+			best, bestDiff = res, diff
+		}
+	}
+	return best
+}
+
+// handleH3Tile serves /tiles/h3/{z}/{x}/{y}.mvt: H3 cell polygons covering the tile,
+// at a resolution chosen from the zoom level (override with ?resolution=).
+// Each feature carries h3_index and resolution properties in the "h3" layer.
+func (s *Server) handleH3Tile(w http.ResponseWriter, r *http.Request) {
+	tile, err := mvt.ParseTileID(r.PathValue("z"), r.PathValue("x"), r.PathValue("y"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, APIError{Code: ErrCodeInvalidValue, Message: err.Error()})
+		return
+	}
+
+	resolution, ok := queryResolution(w, r, h3ResolutionForZoom(tile))
+	if !ok {
+		return
+	}
+
+	if tile.Z < h3TileMinZoom {
+		w.Header().Set("Cache-Control", tileCacheControl)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	minLng, minLat, maxLng, maxLat := tile.Bounds()
+	bufLng := (maxLng - minLng) * tileBufferFraction
+	bufLat := (maxLat - minLat) * tileBufferFraction
+
+	buffered := BBox{MinLat: minLat - bufLat, MinLng: minLng - bufLng, MaxLat: maxLat + bufLat, MaxLng: maxLng + bufLng}
+	if estimate := BBoxRegion(buffered).EstimateCells(resolution); estimate > h3TileMaxCells {
+		writeError(w, http.StatusUnprocessableEntity, APIError{
+			Code:    ErrCodeOutOfRange,
+			Field:   "resolution",
+			Message: fmt.Sprintf("tile %s at resolution %d would have about %d cells, more than the limit of %d", tile, resolution, estimate, h3TileMaxCells),
+		})
+		return
+	}
+
+	loop := h3.GeoLoop{
+		{Lat: minLat - bufLat, Lng: minLng - bufLng},
+		{Lat: minLat - bufLat, Lng: maxLng + bufLng},
+		{Lat: maxLat + bufLat, Lng: maxLng + bufLng},
+		{Lat: maxLat + bufLat, Lng: minLng - bufLng},
+	}
+	cells, err := h3.PolygonToCellsExperimental(h3.GeoPolygon{GeoLoop: loop}, resolution, h3.ContainmentOverlapping)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, APIError{
+			Code:    ErrCodeInternal,
+			Message: fmt.Sprintf("Error generating H3 cells for tile %s: %v", tile, err),
+		})
+		return
+	}
+	if len(cells) > h3TileMaxCells {
+		writeError(w, http.StatusUnprocessableEntity, APIError{
+			Code:    ErrCodeOutOfRange,
+			Field:   "resolution",
+			Message: fmt.Sprintf("tile %s at resolution %d has %d cells, more than the limit of %d", tile, resolution, len(cells), h3TileMaxCells),
+		})
+		return
+	}
+
+	layer := mvt.NewLayer("h3", mvt.DefaultExtent)
+	for _, cell := range cells {
+		boundary, err := cell.Boundary()
+		if err != nil {
+			continue
+		}
+		center, err := cell.LatLng()
+		if err != nil {
+			continue
+		}
+
+		ring := make([]mvt.Point, len(boundary))
+		for i, ll := range boundary {
+			ring[i] = tile.Project(unwrapLng(ll.Lng, center.Lng), ll.Lat, layer.Extent)
+		}
+
+		layer.AddPolygon(uint64(cell), [][]mvt.Point{ring}, map[string]any{
+			"h3_index":   cell.String(),
+			"resolution": resolution,
+		})
+	}
+
+	writeTile(w, &mvt.Tile{Layers: []*mvt.Layer{layer}})
+}
+
+// unwrapLng shifts lng by ±360 so it lies within 180° of ref, keeping polygons
+// that cross the antimeridian contiguous
+func unwrapLng(lng, ref float64) float64 {
+	switch {
+	case lng-ref > 180:
+		return lng - 360
+	case ref-lng > 180:
+		return lng + 360
+	}
+	return lng
+}
+
+// handleTripTile serves /tiles/trip[s/{trip}]/{z}/{x}/{y}.mvt with two layers:
+// "routes" (cached route lines, simplified for the zoom and clipped to the tile)
+// and "locations" (points at their H3 cell centers). Properties match
+// /api/routes/lines and /api/locations.
+func (s *Server) handleTripTile(w http.ResponseWriter, r *http.Request) {
+	tile, err := mvt.ParseTileID(r.PathValue("z"), r.PathValue("x"), r.PathValue("y"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, APIError{Code: ErrCodeInvalidValue, Message: err.Error()})
+		return
+	}
+
+	trip, ok := s.tripFromRequest(w, r)
+	if !ok {
+		return
+	}
+	locations, routes, ok := s.tripPlaces(w, r, trip)
+	if !ok {
+		return
+	}
+
+	routeLayer := mvt.NewLayer("routes", mvt.DefaultExtent)
+	for _, route := range CachedRoutesFor(routes) {
+		line := make([]mvt.Coord, len(route.Geometry))
+		for i, coord := range route.Geometry {
+			x, y := tile.ProjectFloat(coord[0], coord[1], routeLayer.Extent)
+			line[i] = mvt.Coord{X: x, Y: y}
+		}
+		line = mvt.Simplify(line, tripTileTolerance)
+
+		props := map[string]any{
+			"route_name": route.Name,
+			"route_type": route.Type,
+			"distance":   route.Distance,
+			"duration":   route.Duration,
+		}
+		for _, part := range mvt.ClipLine(line, -tripTileBuffer, float64(routeLayer.Extent)+tripTileBuffer) {
+			points := make([]mvt.Point, len(part))
+			for i, c := range part {
+				points[i] = c.Round()
+			}
+			routeLayer.AddLineString(uint64(route.RouteID), points, props)
+		}
+	}
+
+	locationsGeoJSON, err := GetLocationsGeoJSON(locations, s.Config.Resolution)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, APIError{
+			Code:    ErrCodeInternal,
+			Message: fmt.Sprintf("Error generating locations: %v", err),
+		})
+		return
+	}
+
+	locationLayer := mvt.NewLayer("locations", mvt.DefaultExtent)
+	for _, feature := range locationsGeoJSON.Features {
+		coords, ok := feature.Geometry.Coordinates.([]float64)
+		if !ok {
+			continue
+		}
+		p := tile.Project(coords[0], coords[1], locationLayer.Extent)
+		if p.X < -tripTileBuffer || p.Y < -tripTileBuffer ||
+			p.X > int32(locationLayer.Extent)+tripTileBuffer || p.Y > int32(locationLayer.Extent)+tripTileBuffer {
+			continue
+		}
+		id, _ := feature.Properties["id"].(int64)
+		locationLayer.AddPoint(uint64(id), p, feature.Properties)
+	}
+
+	w.Header().Set("Cache-Control", tripTileCacheControl)
+	writeTile(w, &mvt.Tile{Layers: []*mvt.Layer{routeLayer, locationLayer}})
+}
+
+// writeTile writes an encoded vector tile. Cache-Control defaults to tileCacheControl
+// unless the caller has already set it.
+func writeTile(w http.ResponseWriter, tile *mvt.Tile) {
+	data := tile.Marshal()
+
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", tileCacheControl)
+	}
+	if len(data) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", mvtContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
@@ -0,0 +1,228 @@
+package internal
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/hunterjsb/tokygo/internal/mvt"
+)
+
+// tileFeature is a feature read back from an encoded tile, with its geometry
+// replayed into absolute tile coordinates (one path per MoveTo)
+type tileFeature struct {
+	id    uint64
+	paths [][]mvt.Point
+}
+
+// protoFields splits one protobuf message into field number and payload pairs.
+// Varint payloads are returned as their value; length-delimited ones as bytes.
+func protoFields(t *testing.T, buf []byte) (numbers []int, varints []uint64, bytes [][]byte) {
+	t.Helper()
+	for len(buf) > 0 {
+		key, n := binary.Uvarint(buf)
+		if n <= 0 {
+			t.Fatal("bad field key")
+		}
+		buf = buf[n:]
+		var v uint64
+		var b []byte
+		switch key & 7 {
+		case 0:
+			v, n = binary.Uvarint(buf)
+			if n <= 0 {
+				t.Fatal("bad varint")
+			}
+			buf = buf[n:]
+		case 1:
+			buf = buf[8:]
+		case 2:
+			size, n := binary.Uvarint(buf)
+			if n <= 0 || int(size) > len(buf)-n {
+				t.Fatal("bad length")
+			}
+			b, buf = buf[n:n+int(size)], buf[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		numbers, varints, bytes = append(numbers, int(key>>3)), append(varints, v), append(bytes, b)
+	}
+	return numbers, varints, bytes
+}
+
+// decodeTileLayers returns the features of each layer in an encoded tile
+func decodeTileLayers(t *testing.T, data []byte) map[string][]tileFeature {
+	t.Helper()
+	layers := map[string][]tileFeature{}
+	_, _, layerData := protoFields(t, data)
+	for _, layer := range layerData {
+		var name string
+		var features []tileFeature
+		numbers, _, payloads := protoFields(t, layer)
+		for i, number := range numbers {
+			switch number {
+			case 1:
+				name = string(payloads[i])
+			case 2:
+				features = append(features, decodeTileFeature(t, payloads[i]))
+			}
+		}
+		layers[name] = features
+	}
+	return layers
+}
+
+func decodeTileFeature(t *testing.T, data []byte) tileFeature {
+	t.Helper()
+	var f tileFeature
+	numbers, varints, payloads := protoFields(t, data)
+	for i, number := range numbers {
+		switch number {
+		case 1:
+			f.id = varints[i]
+		case 4:
+			var geometry []uint32
+			for buf := payloads[i]; len(buf) > 0; {
+				v, n := binary.Uvarint(buf)
+				geometry, buf = append(geometry, uint32(v)), buf[n:]
+			}
+			f.paths = replayGeometry(geometry)
+		}
+	}
+	return f
+}
+
+func replayGeometry(geometry []uint32) [][]mvt.Point {
+	unzigzag := func(v uint32) int32 { return int32(v>>1) ^ -int32(v&1) }
+
+	var paths [][]mvt.Point
+	var cursor mvt.Point
+	for i := 0; i < len(geometry); {
+		id, count := geometry[i]&7, int(geometry[i]>>3)
+		i++
+		if id == 7 { // ClosePath
+			continue
+		}
+		if id == 1 { // MoveTo
+			paths = append(paths, nil)
+		}
+		for range count {
+			cursor.X += unzigzag(geometry[i])
+			cursor.Y += unzigzag(geometry[i+1])
+			i += 2
+			paths[len(paths)-1] = append(paths[len(paths)-1], cursor)
+		}
+	}
+	return paths
+}
+
+// tileLngLat returns the coordinates at fraction (fx, fy) across a tile, which
+// may lie outside it
+func tileLngLat(tile mvt.TileID, fx, fy float64) (lng, lat float64) {
+	n := math.Exp2(float64(tile.Z))
+	lng = (float64(tile.X)+fx)/n*360 - 180
+	lat = math.Atan(math.Sinh(math.Pi*(1-2*(float64(tile.Y)+fy)/n))) * 180 / math.Pi
+	return lng, lat
+}
+
+func getTripTile(t *testing.T, s *Server, tile mvt.TileID) map[string][]tileFeature {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/tiles/trip/"+tile.String()+".mvt", nil)
+	r.SetPathValue("z", strconv.FormatUint(uint64(tile.Z), 10))
+	r.SetPathValue("x", strconv.FormatUint(uint64(tile.X), 10))
+	r.SetPathValue("y", strconv.FormatUint(uint64(tile.Y), 10)+".mvt")
+	w := httptest.NewRecorder()
+	s.handleTripTile(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	return decodeTileLayers(t, w.Body.Bytes())
+}
+
+func TestTripTileClipsRoutes(t *testing.T) {
+	s := newTestServer(t, DefaultConfig())
+	routes, err := s.Store.Routes(context.Background(), DefaultTripID)
+	if err != nil || len(routes) == 0 {
+		t.Fatalf("no seeded routes: %v", err)
+	}
+	route := routes[0]
+
+	// A route that runs down out of the tile and comes back up into it
+	tile := mvt.TileID{Z: 11, X: 1818, Y: 806}
+	var geometry [][]float64
+	for _, f := range [][2]float64{{0.2, 0.2}, {0.2, 0.6}, {0.5, 3}, {0.8, 0.6}, {0.8, 0.2}} {
+		lng, lat := tileLngLat(tile, f[0], f[1])
+		geometry = append(geometry, []float64{lng, lat})
+	}
+	saved := CachedRoutes
+	t.Cleanup(func() { CachedRoutes = saved })
+	CachedRoutes = []CachedRoute{{
+		Type:        route.Type,
+		Origin:      route.Origin,
+		Destination: route.Destination,
+		Geometry:    geometry,
+	}}
+
+	features := getTripTile(t, s, tile)["routes"]
+	if len(features) != 2 {
+		t.Fatalf("routes layer has %d features, want the route split in 2", len(features))
+	}
+	for _, f := range features {
+		if f.id != uint64(route.ID) {
+			t.Errorf("feature id = %d, want route %d", f.id, route.ID)
+		}
+		for _, path := range f.paths {
+			for _, p := range path {
+				if p.X < -tripTileBuffer || p.Y < -tripTileBuffer ||
+					p.X > mvt.DefaultExtent+tripTileBuffer || p.Y > mvt.DefaultExtent+tripTileBuffer {
+					t.Errorf("point %v is outside the tile buffer", p)
+				}
+			}
+			// Each piece is cut at the bottom edge of the buffer
+			if last := path[len(path)-1]; path[0].Y != mvt.DefaultExtent+tripTileBuffer && last.Y != mvt.DefaultExtent+tripTileBuffer {
+				t.Errorf("piece %v does not end at the buffer edge", path)
+			}
+		}
+	}
+}
+
+func TestTripTileDropsOutsideLocations(t *testing.T) {
+	s := newTestServer(t, DefaultConfig())
+	tile := mvt.TileID{Z: 11, X: 1818, Y: 806}
+
+	var inside, outside TripLocation
+	for i, f := range [][2]float64{{0.5, 0.5}, {2.5, 0.5}} {
+		lng, lat := tileLngLat(tile, f[0], f[1])
+		loc, err := s.Store.CreateLocation(context.Background(), TripLocation{
+			TripID: DefaultTripID,
+			Name:   "Test " + strconv.Itoa(i),
+			Type:   LocationTypeSight,
+			City:   "Tokyo",
+			Lat:    lat,
+			Lng:    lng,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			inside = loc
+		} else {
+			outside = loc
+		}
+	}
+
+	found := map[uint64]bool{}
+	for _, f := range getTripTile(t, s, tile)["locations"] {
+		found[f.id] = true
+	}
+	if !found[uint64(inside.ID)] {
+		t.Errorf("location %d inside the tile is missing", inside.ID)
+	}
+	if found[uint64(outside.ID)] {
+		t.Errorf("location %d outside the tile was included", outside.ID)
+	}
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+	_ "time/tzdata" // embed the zone database so trip timezones load on minimal hosts
+)
+
+// tripDateLayout is the format of Trip start and end dates
+const tripDateLayout = "2006-01-02"
+
+// Trip is a single journey that owns its cities, locations and routes
+type Trip struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	StartDate string `json:"startDate"` // YYYY-MM-DD, inclusive
+	EndDate   string `json:"endDate"`   // YYYY-MM-DD, inclusive
+	Timezone  string `json:"timezone"`  // IANA home timezone, e.g. "Asia/Tokyo"
+}
+
+// DefaultTripID identifies the trip served by the unscoped /api/... endpoints
+const DefaultTripID int64 = 1
+
+// DefaultTrip is the seed trip that owns Cities, TripLocations and TripRoutes
+var DefaultTrip = Trip{
+	ID:        DefaultTripID,
+	Name:      "Japan",
+	StartDate: "2025-11-03",
+	EndDate:   "2025-11-10",
+	Timezone:  "Asia/Tokyo",
+}
+
+// Location returns the trip's home timezone
+func (t Trip) Location() (*time.Location, error) {
+	return time.LoadLocation(t.Timezone)
+}
+
+// ValidateTrip checks a trip's name, dates and timezone.
+// It returns one APIError per invalid field, or nil if the trip is valid.
+func ValidateTrip(trip Trip) []APIError {
+	var errs []APIError
+
+	if trip.Name == "" {
+		errs = append(errs, APIError{Code: ErrCodeRequired, Field: "name", Message: "name is required"})
+	}
+
+	start, startErr := time.Parse(tripDateLayout, trip.StartDate)
+	if startErr != nil {
+		errs = append(errs, APIError{Code: ErrCodeInvalidValue, Field: "startDate", Message: "startDate must be YYYY-MM-DD"})
+	}
+	end, endErr := time.Parse(tripDateLayout, trip.EndDate)
+	if endErr != nil {
+		errs = append(errs, APIError{Code: ErrCodeInvalidValue, Field: "endDate", Message: "endDate must be YYYY-MM-DD"})
+	}
+	if startErr == nil && endErr == nil && end.Before(start) {
+		errs = append(errs, APIError{Code: ErrCodeOutOfRange, Field: "endDate", Message: "endDate must not be before startDate"})
+	}
+
+	if trip.Timezone == "" {
+		errs = append(errs, APIError{Code: ErrCodeRequired, Field: "timezone", Message: "timezone is required"})
+	} else if _, err := trip.Location(); err != nil {
+		errs = append(errs, APIError{
+			Code:    ErrCodeInvalidValue,
+			Field:   "timezone",
+			Message: fmt.Sprintf("unknown timezone %q", trip.Timezone),
+		})
+	}
+
+	return errs
+}
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// ValidateCity checks a city's name, coordinates and color against the trip's
+// existing cities. It returns one APIError per invalid field, or nil if valid.
+func ValidateCity(city City, existing []City) []APIError {
+	var errs []APIError
+
+	if city.Name == "" {
+		errs = append(errs, APIError{Code: ErrCodeRequired, Field: "name", Message: "name is required"})
+	} else if slices.ContainsFunc(existing, func(c City) bool { return c.Name == city.Name }) {
+		errs = append(errs, APIError{
+			Code:    ErrCodeInvalidValue,
+			Field:   "name",
+			Message: fmt.Sprintf("city %q already exists in this trip", city.Name),
+		})
+	}
+	if city.Lat < -90 || city.Lat > 90 {
+		errs = append(errs, APIError{Code: ErrCodeOutOfRange, Field: "lat", Message: "lat must be between -90 and 90"})
+	}
+	if city.Lng < -180 || city.Lng > 180 {
+		errs = append(errs, APIError{Code: ErrCodeOutOfRange, Field: "lng", Message: "lng must be between -180 and 180"})
+	}
+	if city.Color != "" && !colorPattern.MatchString(city.Color) {
+		errs = append(errs, APIError{Code: ErrCodeInvalidValue, Field: "color", Message: "color must be a #rrggbb hex value"})
+	}
+
+	return errs
+}
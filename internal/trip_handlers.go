@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// tripFromRequest resolves the {trip} path value to a stored Trip.
+// Unscoped /api/... paths resolve to DefaultTripID. On failure it writes
+// a 400 or 404 response and returns false.
+func (s *Server) tripFromRequest(w http.ResponseWriter, r *http.Request) (Trip, bool) {
+	id := DefaultTripID
+	if raw := r.PathValue("trip"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, APIError{
+				Code:    ErrCodeInvalidID,
+				Field:   "trip",
+				Message: fmt.Sprintf("invalid trip id %q", raw),
+			})
+			return Trip{}, false
+		}
+		id = parsed
+	}
+
+	trip, err := s.Store.Trip(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, err, "trip", id)
+		return Trip{}, false
+	}
+	return trip, true
+}
+
+// handleTripsCollection serves /api/trips: GET lists trips, POST creates a trip.
+func (s *Server) handleTripsCollection(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		trips, err := s.Store.Trips(r.Context())
+		if err != nil {
+			writeStoreError(w, err, "trips", 0)
+			return
+		}
+		okJSON(w, TripsResponse{Trips: trips})
+	case http.MethodPost:
+		var trip Trip
+		if !decodeJSONBody(w, r, &trip) {
+			return
+		}
+		trip.ID = 0
+
+		if !checkValidation(w, "trip", ValidateTrip(trip)) {
+			return
+		}
+
+		created, err := s.Store.CreateTrip(r.Context(), trip)
+		if err != nil {
+			writeStoreError(w, err, "trip", 0)
+			return
+		}
+
+		w.Header().Set("Location", fmt.Sprintf("/api/trips/%d", created.ID))
+		writeJSON(w, http.StatusCreated, created)
+	default:
+		methodNotAllowed(w, "GET, POST, OPTIONS")
+	}
+}
+
+// handleTripItem serves /api/trips/{trip}:
+// GET returns the trip, PUT replaces it, DELETE removes it with everything it owns.
+func (s *Server) handleTripItem(w http.ResponseWriter, r *http.Request) {
+	trip, ok := s.tripFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		okJSON(w, trip)
+	case http.MethodPut:
+		var updated Trip
+		if !decodeJSONBody(w, r, &updated) {
+			return
+		}
+		updated.ID = trip.ID
+
+		if !checkValidation(w, "trip", ValidateTrip(updated)) {
+			return
+		}
+
+		updated, err := s.Store.UpdateTrip(r.Context(), updated)
+		if err != nil {
+			writeStoreError(w, err, "trip", trip.ID)
+			return
+		}
+		okJSON(w, updated)
+	case http.MethodDelete:
+		// The unscoped /api/... endpoints serve the default trip
+		if trip.ID == DefaultTripID {
+			writeError(w, http.StatusConflict, APIError{
+				Code:    ErrCodeConflict,
+				Message: fmt.Sprintf("trip %d is the default trip and cannot be deleted", trip.ID),
+			})
+			return
+		}
+		if err := s.Store.DeleteTrip(r.Context(), trip.ID); err != nil {
+			writeStoreError(w, err, "trip", trip.ID)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, "GET, PUT, DELETE, OPTIONS")
+	}
+}
+
+// handleCitiesCollection serves /api/[trips/{trip}/]cities:
+// GET lists the trip's cities, POST adds a city to the trip.
+func (s *Server) handleCitiesCollection(w http.ResponseWriter, r *http.Request) {
+	trip, ok := s.tripFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.handleCities(w, r, trip)
+	case http.MethodPost:
+		var city City
+		if !decodeJSONBody(w, r, &city) {
+			return
+		}
+		city.TripID = trip.ID
+
+		existing, err := s.Store.Cities(r.Context(), trip.ID)
+		if err != nil {
+			writeStoreError(w, err, "cities", 0)
+			return
+		}
+		if !checkValidation(w, "city", ValidateCity(city, existing)) {
+			return
+		}
+
+		created, err := s.Store.CreateCity(r.Context(), city)
+		if err != nil {
+			writeStoreError(w, err, "city", 0)
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+	default:
+		methodNotAllowed(w, "GET, POST, OPTIONS")
+	}
+}